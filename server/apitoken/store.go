@@ -0,0 +1,159 @@
+package apitoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fif/middleware"
+	"fmt"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/lib/pq"
+)
+
+// lastUsedResolution limits how often last_used_at is written for a busy token
+const lastUsedResolution = time.Minute
+
+// ErrNotFound is returned when a token does not exist or belongs to someone else
+var ErrNotFound = errors.New("api token not found")
+
+// Token describes a personal access token; the secret itself is never stored
+type Token struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hint       string     `json:"hint"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// Store keeps personal access tokens in the api_tokens table. It implements
+// middleware.APITokenVerifier.
+type Store struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewStore creates a Store backed by db
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db, now: time.Now}
+}
+
+// Create issues a token for userID and returns the secret, which cannot be
+// recovered later, along with its description
+func (s *Store) Create(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (string, Token, error) {
+	raw, err := Generate()
+	if err != nil {
+		return "", Token{}, err
+	}
+
+	t := Token{Name: name, Hint: Hint(raw), Scopes: scopes, ExpiresAt: expiresAt}
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO api_tokens (user_id, name, token_hash, hint, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, userID, name, Hash(raw), t.Hint, pq.Array(scopes), expiresAt).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return "", Token{}, fmt.Errorf("failed to create api token: %w", err)
+	}
+	return raw, t, nil
+}
+
+// List returns every token of userID, newest first, including revoked and
+// expired ones
+func (s *Store) List(ctx context.Context, userID string) ([]Token, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, hint, scopes, expires_at, last_used_at, revoked_at, created_at
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		var t Token
+		if err := rows.Scan(&t.ID, &t.Name, &t.Hint, pq.Array(&t.Scopes), &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api token: %w", err)
+		}
+		if t.Scopes == nil {
+			t.Scopes = []string{}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke stops a token of userID from being accepted. Revoking a token twice
+// is not an error.
+func (s *Store) Revoke(ctx context.Context, userID, id string) error {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE api_tokens
+		SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id::text = $1 AND user_id = $2
+	`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api token: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// VerifyAPIToken looks up raw and returns a token for its owner carrying the
+// scopes it was issued with. It records when the token was last used.
+func (s *Store) VerifyAPIToken(ctx context.Context, raw string) (*auth.Token, error) {
+	if !wellFormed(raw) {
+		return nil, &middleware.AuthError{Reason: middleware.ReasonMalformed, Err: errors.New("malformed api token")}
+	}
+
+	var id, userID string
+	var scopes []string
+	var expiresAt time.Time
+	var revokedAt *time.Time
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, scopes, expires_at, revoked_at
+		FROM api_tokens
+		WHERE token_hash = $1
+	`, Hash(raw)).Scan(&id, &userID, pq.Array(&scopes), &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, &middleware.AuthError{Reason: middleware.ReasonInvalid, Err: ErrNotFound}
+	}
+	if err != nil {
+		return nil, &middleware.AuthError{Reason: middleware.ReasonUnavailable, Err: err}
+	}
+
+	now := s.now()
+	if revokedAt != nil {
+		return nil, &middleware.AuthError{Reason: middleware.ReasonRevoked, Err: fmt.Errorf("api token %s was revoked", id)}
+	}
+	if !now.Before(expiresAt) {
+		return nil, &middleware.AuthError{Reason: middleware.ReasonExpired, Err: fmt.Errorf("api token %s expired", id)}
+	}
+
+	// Best effort: a failed write should not reject an otherwise valid token
+	s.db.ExecContext(ctx, `
+		UPDATE api_tokens
+		SET last_used_at = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)
+	`, id, now, now.Add(-lastUsedResolution))
+
+	claims := map[string]interface{}{
+		middleware.ClaimAPITokenID: id,
+		middleware.ClaimScopes:     scopes,
+	}
+	return &auth.Token{
+		UID:      userID,
+		Subject:  userID,
+		Expires:  expiresAt.Unix(),
+		IssuedAt: now.Unix(),
+		Claims:   claims,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fif/middleware"
+	"fmt"
+	"strings"
+)
+
+// secretBytes is the amount of randomness in a token
+const secretBytes = 32
+
+// hintLength is how many trailing characters of a token are kept in clear
+const hintLength = 4
+
+// Generate returns a new random token, which starts with middleware.APITokenPrefix
+func Generate() (string, error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return middleware.APITokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Hash returns the value stored for a token. Tokens have enough entropy that
+// a fast unsalted hash is safe and lets them be looked up directly.
+func Hash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// Hint returns the trailing characters of a token shown when listing tokens
+func Hint(raw string) string {
+	if len(raw) <= hintLength {
+		return raw
+	}
+	return raw[len(raw)-hintLength:]
+}
+
+// ValidScope reports whether scope is one of middleware.KnownScopes
+func ValidScope(scope string) bool {
+	for _, known := range middleware.KnownScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// wellFormed checks the shape of a token before it is looked up
+func wellFormed(raw string) bool {
+	secret, ok := strings.CutPrefix(raw, middleware.APITokenPrefix)
+	if !ok {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(secret)
+	return err == nil && len(b) == secretBytes
+}
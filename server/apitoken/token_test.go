@@ -0,0 +1,57 @@
+package apitoken
+
+import (
+	"fif/middleware"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	// Create two tokens
+	a, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	b, err := Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	// Assert they are prefixed, well formed and distinct
+	if !strings.HasPrefix(a, middleware.APITokenPrefix) {
+		t.Errorf("Expected prefix %q, got %q", middleware.APITokenPrefix, a)
+	}
+	if !wellFormed(a) {
+		t.Errorf("Expected %q to be well formed", a)
+	}
+	if a == b || Hash(a) == Hash(b) {
+		t.Error("Expected distinct tokens and hashes")
+	}
+}
+
+func TestWellFormed(t *testing.T) {
+	testCases := []struct {
+		raw      string
+		expected bool
+	}{
+		{middleware.APITokenPrefix + strings.Repeat("A", 43), true},
+		{middleware.APITokenPrefix + "short", false},
+		{middleware.APITokenPrefix + strings.Repeat("!", 43), false},
+		{strings.Repeat("A", 43), false},
+	}
+
+	for _, tc := range testCases {
+		if got := wellFormed(tc.raw); got != tc.expected {
+			t.Errorf("wellFormed(%q) = %v, expected %v", tc.raw, got, tc.expected)
+		}
+	}
+}
+
+func TestValidScope(t *testing.T) {
+	if !ValidScope(middleware.ScopeHoldingsRead) {
+		t.Error("Expected holdings:read to be valid")
+	}
+	if ValidScope("admin") {
+		t.Error("Expected admin not to be a scope")
+	}
+}
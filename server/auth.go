@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fif/config"
+	"fif/devauth"
+	"fif/health"
+	"fif/middleware"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// newAuthMiddleware builds the middleware that verifies ID tokens, and a
+// readiness check that the verifier can still verify them. The auth mode
+// selects Firebase (the default) or locally signed dev tokens.
+func newAuthMiddleware(cfg *config.Config, opts ...middleware.AuthOption) (func(http.Handler) http.Handler, health.CheckFunc, error) {
+	// A TTL of 0 disables the token cache
+	if cfg.Auth.CacheTTL > 0 {
+		opts = append(opts, middleware.WithTokenCache(middleware.NewTokenCache(cfg.Auth.CacheSize, cfg.Auth.CacheTTL)))
+	}
+
+	if cfg.Auth.DemoUserID != "" {
+		slog.Info("Serving the demo portfolio to visitors without credentials", "uid", cfg.Auth.DemoUserID)
+		opts = append(opts, middleware.WithDemoUser(cfg.Auth.DemoUserID))
+	}
+
+	switch cfg.Auth.Mode {
+	case "firebase":
+		firebase, err := initFirebaseApp(cfg.Auth.FirebaseCredentials)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error initializing firebase app: %w", err)
+		}
+
+		authClient, err := firebase.Auth(context.Background())
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting auth client: %w", err)
+		}
+
+		// Checking revocation costs a Firebase call per request, so it is opt-in
+		opts = append(opts, middleware.WithRevocationCheck(cfg.Auth.CheckRevoked))
+		return middleware.AuthMiddleware(authClient, opts...), checkFirebaseClient(authClient), nil
+
+	case "dev":
+		if err := devauth.Guard(cfg.AppEnv); err != nil {
+			return nil, nil, err
+		}
+
+		key, err := devauth.LoadOrCreateKey(cfg.Auth.DevKeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		verifier := devauth.New(key)
+
+		slog.Warn("Accepting dev tokens; mint them with `go run ./cmd/devtoken`", "key_file", cfg.Auth.DevKeyFile)
+		return middleware.VerifierMiddleware(verifier, opts...), checkDevVerifier(verifier), nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown AUTH_MODE %q", cfg.Auth.Mode)
+	}
+}
+
+// checkFirebaseClient fails when no Firebase auth client was built. It does
+// not call Google: the Admin SDK caches the signing keys, so a short outage
+// there should not take every replica out of the load balancer.
+func checkFirebaseClient(client *auth.Client) health.CheckFunc {
+	return func(ctx context.Context) error {
+		if client == nil {
+			return errors.New("no Firebase auth client")
+		}
+		return nil
+	}
+}
+
+// checkDevVerifier mints a short-lived token with the loaded dev key and
+// verifies it, so the check fails if the key is not usable
+func checkDevVerifier(verifier *devauth.Verifier) health.CheckFunc {
+	return func(ctx context.Context) error {
+		token, err := verifier.Mint("readyz", "", time.Minute, nil)
+		if err != nil {
+			return fmt.Errorf("minting a dev token: %w", err)
+		}
+		if _, err := verifier.VerifyIDToken(ctx, token); err != nil {
+			return fmt.Errorf("verifying a dev token: %w", err)
+		}
+		return nil
+	}
+}
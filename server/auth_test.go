@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fif/devauth"
+	"testing"
+)
+
+func TestCheckFirebaseClient(t *testing.T) {
+	// Assert a missing client fails without any network call
+	if err := checkFirebaseClient(nil)(context.Background()); err == nil {
+		t.Error("Expected the check to fail without a client")
+	}
+}
+
+func TestCheckDevVerifier(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert a verifier with a usable key passes
+	if err := checkDevVerifier(devauth.New(key))(context.Background()); err != nil {
+		t.Errorf("Expected the check to pass, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fif/config"
+	"fif/demo"
+	"fif/logging"
+	"fif/middleware"
+	"fif/migrations"
+	"fif/portfolio"
+	"fif/tax"
+	"fif/users"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+//go:embed seed.sql
+var seedSQL string
+
+// command is a subcommand of the server binary that works on the database
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(ctx context.Context, db *sql.DB, args []string) error
+}
+
+var commands = []command{
+	{"migrate", "migrate [up|status]", "apply pending migrations, or show the schema version", runMigrate},
+	{"seed", "seed", "load the sample holdings from seed.sql, replacing earlier copies", runSeed},
+	{"demo", "demo -user UID [-years N] [-seed N]", "generate a demo portfolio with prices, rates and daily history", runDemo},
+	{"import", "import -user UID [-replace] FILE", "import holdings for a user from CSV (- reads stdin)", runImport},
+	{"calc", "calc -user UID [-year YYYY] [-format table|json]", "calculate FIF income for an income year", runCalc},
+	{"user", "user list | user set-roles UID [ROLE...]", "list users or replace a user's roles", runUser},
+}
+
+func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+
+	switch name {
+	case "serve":
+		serve()
+		return
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if err := runCommand(cmd, args); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+func usage(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Usage: server [command]")
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "  serve\trun the HTTP server (the default)")
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.usage, cmd.summary)
+	}
+	tw.Flush()
+}
+
+// runCommand loads the database settings, connects and runs cmd, cancelling
+// it on SIGINT or SIGTERM
+func runCommand(cmd command, args []string) error {
+	cfg, err := config.LoadForCommand()
+	if err != nil {
+		return err
+	}
+	logging.Setup(cfg.Log.Format, cfg.Log.Level)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := InitDB(ctx, cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return cmd.run(ctx, db, args)
+}
+
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		for _, version := range applied {
+			fmt.Printf("applied %04d\n", version)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("already up to date")
+		}
+		return nil
+	case "status":
+		current, err := migrations.Current(ctx, db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %d, latest %d\n", current, migrations.Latest())
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q, want up or status", action)
+	}
+}
+
+func runSeed(ctx context.Context, db *sql.DB, args []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, seedSQL); err != nil {
+		return fmt.Errorf("failed to load seed data: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Println("loaded seed.sql")
+	return nil
+}
+
+func runDemo(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("demo", flag.ContinueOnError)
+	userID := flags.String("user", "", "user to give the demo portfolio (required)")
+	years := flags.Int("years", demo.DefaultYears, "complete income years of history")
+	seed := flags.Uint64("seed", demo.DefaultSeed, "seed for the price paths")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userID == "" || flags.NArg() != 0 {
+		return errors.New("usage: demo -user UID [-years N] [-seed N]")
+	}
+
+	summary, err := demo.Generate(ctx, db, *userID, demo.Options{Years: *years, Seed: *seed})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("generated %s to %s for %s: %d holdings, %d prices, %d dividends, %d snapshots\n",
+		summary.From.Format(portfolio.DateLayout), summary.To.Format(portfolio.DateLayout), *userID,
+		summary.Holdings, summary.Prices, summary.Dividends, summary.Snapshots)
+	return nil
+}
+
+func runImport(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	userID := flags.String("user", "", "user to import holdings for (required)")
+	replace := flags.Bool("replace", false, "delete the user's existing holdings first")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userID == "" || flags.NArg() != 1 {
+		return errors.New("usage: import -user UID [-replace] FILE")
+	}
+
+	in, source := os.Stdin, "stdin"
+	if path := flags.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in, source = f, filepath.Base(path)
+	}
+
+	holdings, err := portfolio.ParseHoldingsCSV(in)
+	if err == nil {
+		err = portfolio.ImportHoldings(ctx, db, *userID, holdings, *replace)
+	}
+	if err != nil {
+		// Kept for /api/admin/import-failures
+		if recordErr := portfolio.RecordImportFailure(ctx, db, *userID, source, err); recordErr != nil {
+			fmt.Fprintf(os.Stderr, "could not record the failure: %v\n", recordErr)
+		}
+		return err
+	}
+	fmt.Printf("imported %d holdings for %s\n", len(holdings), *userID)
+	return nil
+}
+
+func runCalc(ctx context.Context, db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("calc", flag.ContinueOnError)
+	userID := flags.String("user", "", "user to calculate for (required)")
+	year := flags.Int("year", tax.IncomeYear(tax.Date(time.Now()))-1, "income year, named by the year it ends in")
+	format := flags.String("format", "table", "output format: table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return errors.New("usage: calc -user UID [-year YYYY] [-format table|json]")
+	}
+
+	report, err := tax.Calculate(ctx, db, *userID, *year)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		return writeJSONTo(os.Stdout, report)
+	case "table":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "Symbol\tCurrency\tQuantity\tOpening NZD\tIncome NZD\t\n")
+		for _, h := range report.Holdings {
+			fmt.Fprintf(tw, "%s\t%s\t%.4f\t%s\t%s\t\n", h.Symbol, h.Currency, h.Quantity, money(h.OpeningValueNZD), money(h.IncomeNZD))
+		}
+		fmt.Fprintf(tw, "Total\t\t\t%.2f\t%.2f\t\n", report.OpeningValueNZD, report.IncomeNZD)
+		tw.Flush()
+
+		fmt.Printf("\n%s method, income year ended 31 March %d, opening values at %s\n", report.Method, report.IncomeYear, report.OpeningDate)
+		if report.Exempt {
+			fmt.Printf("Cost NZ$%.2f is within the NZ$%.0f de minimis threshold, so no FIF income is due\n", report.CostNZD, tax.DeMinimisThreshold)
+		}
+		if !report.Complete {
+			fmt.Println("Holdings marked - had no opening price or FX rate and are left out of the totals")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown format %q, want table or json", *format)
+	}
+}
+
+func runUser(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: user list | user set-roles UID [ROLE...]")
+	}
+
+	switch args[0] {
+	case "list":
+		list, err := users.List(ctx, db)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tEmail\tRoles\tHoldings")
+		for _, u := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", u.ID, u.Email, strings.Join(u.Roles, ","), u.Holdings)
+		}
+		return tw.Flush()
+
+	case "set-roles":
+		if len(args) < 2 {
+			return errors.New("usage: user set-roles UID [ROLE...]")
+		}
+		userID, roles := args[1], args[2:]
+		for _, role := range roles {
+			if !slices.Contains(middleware.KnownRoles, role) {
+				return fmt.Errorf("unknown role %q, known roles are %s", role, strings.Join(middleware.KnownRoles, ", "))
+			}
+		}
+		if err := users.SetRoles(ctx, db, userID, roles); err != nil {
+			return err
+		}
+		fmt.Printf("%s now has roles [%s]\n", userID, strings.Join(roles, ","))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown action %q, want list or set-roles", args[0])
+	}
+}
+
+// money formats an optional NZD amount for tables
+func money(v *float64) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *v)
+}
+
+func writeJSONTo(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
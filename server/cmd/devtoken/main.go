@@ -0,0 +1,46 @@
+// Command devtoken mints ID tokens accepted by a server running with
+// AUTH_MODE=dev, for trying the API as arbitrary test users:
+//
+//	APP_ENV=development go run ./cmd/devtoken -uid alice -email alice@example.com -roles admin
+package main
+
+import (
+	"fif/devauth"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	uid := flag.String("uid", "", "user id to put in the token (required)")
+	email := flag.String("email", "", "email claim")
+	roles := flag.String("roles", "", "comma separated roles claim, e.g. admin")
+	ttl := flag.Duration("ttl", devauth.DefaultTTL, "how long the token is valid")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	if err := devauth.Guard(os.Getenv("APP_ENV")); err != nil {
+		log.Fatal(err)
+	}
+
+	key, err := devauth.LoadOrCreateKey(devauth.KeyFileFromEnv())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	extra := map[string]interface{}{}
+	if *roles != "" {
+		extra["roles"] = strings.Split(*roles, ",")
+	}
+
+	token, err := devauth.New(key).Mint(*uid, *email, *ttl, extra)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(token)
+}
@@ -0,0 +1,514 @@
+// Package config loads the server's settings from the environment, a .env
+// file and an optional config file, and validates them before anything
+// starts. Every problem is reported at once rather than one per restart.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fif/devauth"
+	"fif/flags"
+	"fif/logging"
+	"fif/ratelimit"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config is the validated configuration of the server
+type Config struct {
+	// AppEnv is APP_ENV; empty means production
+	AppEnv string
+
+	Server    ServerConfig
+	Security  SecurityConfig
+	Frontend  FrontendConfig
+	Flags     FlagsConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	RateLimit RateLimitConfig
+	SMTP      SMTPConfig
+	Log       LogConfig
+
+	// TracesExporter is OTEL_TRACES_EXPORTER. The exporter itself reads the
+	// standard OTEL_EXPORTER_OTLP_* variables.
+	TracesExporter string
+
+	summary []slog.Attr
+}
+
+// ServerConfig covers the HTTP listeners and shutdown
+type ServerConfig struct {
+	Port              string
+	AllowedOrigins    []string
+	TrustProxyHeaders bool
+	MetricsAddr       string
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+
+	// RequestTimeout is the deadline of each API request's context, which
+	// cancels its SQL when it passes
+	RequestTimeout time.Duration
+
+	ShutdownTimeout time.Duration
+	ShutdownDelay   time.Duration
+}
+
+// SecurityConfig covers the security headers sent with every response
+type SecurityConfig struct {
+	// HSTSMaxAge of 0 sends no Strict-Transport-Security
+	HSTSMaxAge     time.Duration
+	ReferrerPolicy string
+	FrameAncestors string
+	// CSP is empty when no policy is sent; middleware.NonceToken marks the
+	// per-request nonce
+	CSP           string
+	CSPReportOnly bool
+	// CSPReportURI is empty when violations are not reported
+	CSPReportURI string
+}
+
+// FrontendConfig covers the public settings the web app loads at runtime
+type FrontendConfig struct {
+	APIURL string
+	// AuthProvider is how the web app signs users in; only "supabase" for now
+	AuthProvider        string
+	SupabaseURL         string
+	SupabaseAnonKey     string
+	SupabaseRedirectURL string
+}
+
+// FlagsConfig covers feature flags
+type FlagsConfig struct {
+	// File is the path of the flags file, empty for none
+	File string
+	// Defaults are the flags read from File
+	Defaults flags.Set
+	// Refresh is how often database overrides are re-read
+	Refresh time.Duration
+}
+
+// DatabaseConfig covers the Postgres connection pool
+type DatabaseConfig struct {
+	URL            string
+	MigrateOnStart bool
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectTimeout is how long startup keeps retrying an unreachable database
+	ConnectTimeout time.Duration
+	// StatementTimeout is set as Postgres' statement_timeout on every
+	// connection, bounding queries that run outside any request
+	StatementTimeout time.Duration
+}
+
+// AuthConfig covers ID token verification
+type AuthConfig struct {
+	// Mode is "firebase" or "dev"
+	Mode string
+	// FirebaseCredentials is the decoded service account JSON
+	FirebaseCredentials []byte
+	CheckRevoked        bool
+	CacheSize           int
+	// CacheTTL of 0 disables the token cache
+	CacheTTL   time.Duration
+	DevKeyFile string
+	// DemoUserID, when set, lets visitors without credentials read this
+	// user's portfolio
+	DemoUserID string
+}
+
+// RateLimitConfig covers rate limiting
+type RateLimitConfig struct {
+	// Store is "memory" or "postgres"
+	Store  string
+	Limits map[string]ratelimit.Limit
+}
+
+// SMTPConfig covers outgoing email. Without a Host, email is written to
+// OutboxDir instead.
+type SMTPConfig struct {
+	Host      string
+	Port      string
+	Username  string
+	Password  string
+	From      string
+	OutboxDir string
+}
+
+// LogConfig covers logging
+type LogConfig struct {
+	Format string
+	Level  string
+}
+
+// Route groups and their default limits, overridden by RATE_LIMIT_<GROUP>
+// (e.g. RATE_LIMIT_API=600/m or RATE_LIMIT_API=off). "preauth" is applied by
+// client IP before authentication, so failed attempts are limited too.
+var defaultRateLimits = []struct{ group, spec string }{
+	{"public", "60/m"},
+	{"preauth", "600/m"},
+	{"api", "300/m"},
+	{"expensive", "10/m"},
+	{"admin", "120/m"},
+}
+
+// Load reads .env into the environment, as before, then builds the
+// configuration for serving from the environment falling back to CONFIG_FILE,
+// a file of KEY=VALUE lines in the same format
+func Load() (*Config, error) {
+	return loadFrom(true)
+}
+
+// LoadForCommand is Load for the command line tools, which only need the
+// database and logging settings
+func LoadForCommand() (*Config, error) {
+	return loadFrom(false)
+}
+
+func loadFrom(serve bool) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	var file map[string]string
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = godotenv.Read(path); err != nil {
+			return nil, fmt.Errorf("failed to read CONFIG_FILE: %w", err)
+		}
+	}
+
+	return load(func(key string) (string, bool) {
+		if v, ok := os.LookupEnv(key); ok {
+			return v, true
+		}
+		v, ok := file[key]
+		return v, ok
+	}, serve)
+}
+
+// load builds and validates the configuration from lookup. Unless serve is
+// set, only the database and logging settings are read.
+func load(lookup func(string) (string, bool), serve bool) (*Config, error) {
+	l := &loader{lookup: lookup}
+	cfg := &Config{}
+
+	cfg.AppEnv = l.string("APP_ENV", "")
+
+	cfg.Database.URL = l.databaseURL("DATABASE_URL")
+	if cfg.Database.URL == "" {
+		l.fail("DATABASE_URL is required")
+	}
+	cfg.Database.MigrateOnStart = l.bool("MIGRATE_ON_START", true)
+	cfg.Database.MaxOpenConns = l.int("DB_MAX_OPEN_CONNS", 25)
+	cfg.Database.MaxIdleConns = l.int("DB_MAX_IDLE_CONNS", 10)
+	if cfg.Database.MaxOpenConns < 1 || cfg.Database.MaxIdleConns < 0 {
+		l.fail("DB_MAX_OPEN_CONNS must be positive and DB_MAX_IDLE_CONNS not negative")
+	}
+	cfg.Database.ConnMaxLifetime = l.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute)
+	cfg.Database.ConnMaxIdleTime = l.duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute)
+	cfg.Database.ConnectTimeout = l.duration("DB_CONNECT_TIMEOUT", time.Minute)
+	// Generous, as jobs such as price refreshes run long statements
+	cfg.Database.StatementTimeout = l.duration("DB_STATEMENT_TIMEOUT", 5*time.Minute)
+
+	cfg.Log.Format = l.string("LOG_FORMAT", "json")
+	if cfg.Log.Format != "json" && cfg.Log.Format != "text" {
+		l.fail(fmt.Sprintf("LOG_FORMAT must be json or text, not %q", cfg.Log.Format))
+	}
+	cfg.Log.Level = l.string("LOG_LEVEL", "info")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Log.Level)); err != nil {
+		l.fail("LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	// Commands other than serve only talk to the database
+	if !serve {
+		return l.finish(cfg)
+	}
+
+	cfg.Server.Port = l.string("PORT", "8080")
+	if n, err := strconv.Atoi(cfg.Server.Port); err != nil || n < 1 || n > 65535 {
+		l.fail("PORT must be a port number")
+	}
+	cfg.Server.AllowedOrigins = l.list("ALLOWED_ORIGINS")
+	if len(cfg.Server.AllowedOrigins) == 0 {
+		l.fail("ALLOWED_ORIGINS is required")
+	}
+	cfg.Server.TrustProxyHeaders = l.bool("TRUST_PROXY_HEADERS", false)
+	cfg.Server.MetricsAddr = l.string("METRICS_ADDR", ":9090")
+	cfg.Server.ReadHeaderTimeout = l.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second)
+	cfg.Server.ReadTimeout = l.duration("HTTP_READ_TIMEOUT", 15*time.Second)
+	// Long enough for a returns calculation
+	cfg.Server.WriteTimeout = l.duration("HTTP_WRITE_TIMEOUT", 60*time.Second)
+	cfg.Server.IdleTimeout = l.duration("HTTP_IDLE_TIMEOUT", 120*time.Second)
+	cfg.Server.RequestTimeout = l.duration("REQUEST_TIMEOUT", 30*time.Second)
+	if cfg.Server.WriteTimeout > 0 && cfg.Server.RequestTimeout >= cfg.Server.WriteTimeout {
+		l.fail("REQUEST_TIMEOUT must be shorter than HTTP_WRITE_TIMEOUT, so the error reaches the client")
+	}
+	cfg.Server.ShutdownTimeout = l.duration("SHUTDOWN_TIMEOUT", 30*time.Second)
+	cfg.Server.ShutdownDelay = l.duration("SHUTDOWN_DELAY", 0)
+
+	cfg.Frontend.APIURL = l.string("FRONTEND_API_URL", "/api")
+	cfg.Frontend.AuthProvider = l.string("FRONTEND_AUTH_PROVIDER", "supabase")
+	if cfg.Frontend.AuthProvider != "supabase" {
+		l.fail(fmt.Sprintf("FRONTEND_AUTH_PROVIDER must be supabase, not %q", cfg.Frontend.AuthProvider))
+	}
+	// The anon key is public by design; row level security protects the data.
+	// Without them the web app cannot sign anyone in, so fail here rather
+	// than in the browser.
+	cfg.Frontend.SupabaseURL = l.string("SUPABASE_URL", "")
+	if _, ok := originOf(cfg.Frontend.SupabaseURL); !ok {
+		l.fail("SUPABASE_URL is required and must be an http or https URL")
+	}
+	cfg.Frontend.SupabaseAnonKey = l.string("SUPABASE_ANON_KEY", "")
+	if cfg.Frontend.SupabaseAnonKey == "" {
+		l.fail("SUPABASE_ANON_KEY is required")
+	}
+
+	// Browsers remember HSTS, so it stays off while developing over plain HTTP
+	hstsMaxAge := 365 * 24 * time.Hour
+	if cfg.AppEnv == "development" {
+		hstsMaxAge = 0
+	}
+	cfg.Security.HSTSMaxAge = l.duration("HSTS_MAX_AGE", hstsMaxAge)
+	cfg.Security.ReferrerPolicy = l.string("REFERRER_POLICY", "strict-origin-when-cross-origin")
+	cfg.Security.FrameAncestors = l.string("FRAME_ANCESTORS", "'none'")
+	cfg.Security.CSP = l.string("CSP", defaultCSP(cfg.Frontend))
+	if cfg.Security.CSP == "off" {
+		cfg.Security.CSP = ""
+	}
+	if strings.ContainsAny(cfg.Security.CSP, "\r\n") {
+		l.fail("CSP must be on one line")
+	}
+	cfg.Security.CSPReportOnly = l.bool("CSP_REPORT_ONLY", false)
+	cfg.Security.CSPReportURI = l.string("CSP_REPORT_URI", "/api/csp-report")
+	if cfg.Security.CSPReportURI == "off" {
+		cfg.Security.CSPReportURI = ""
+	}
+
+	cfg.Frontend.SupabaseRedirectURL = l.string("SUPABASE_REDIRECT_URL", "")
+
+	cfg.Flags.File = l.string("FEATURE_FLAGS_FILE", "")
+	if cfg.Flags.File != "" {
+		set, err := flags.ParseFile(cfg.Flags.File)
+		if err != nil {
+			l.fail(fmt.Sprintf("FEATURE_FLAGS_FILE: %v", err))
+		}
+		cfg.Flags.Defaults = set
+	}
+	cfg.Flags.Refresh = l.duration("FEATURE_FLAGS_REFRESH", 30*time.Second)
+
+	cfg.Auth.Mode = l.string("AUTH_MODE", "firebase")
+	cfg.Auth.CheckRevoked = l.bool("AUTH_CHECK_REVOKED", false)
+	cfg.Auth.CacheSize = l.int("AUTH_CACHE_SIZE", 10000)
+	if cfg.Auth.CacheSize < 1 {
+		l.fail("AUTH_CACHE_SIZE must be a positive integer")
+	}
+	cfg.Auth.CacheTTL = l.duration("AUTH_CACHE_TTL", 5*time.Minute)
+	cfg.Auth.DevKeyFile = l.string("DEV_AUTH_KEY_FILE", devauth.DefaultKeyFile)
+	cfg.Auth.DemoUserID = l.string("DEMO_USER_ID", "")
+	switch cfg.Auth.Mode {
+	case "firebase":
+		key := l.secret("FIREBASE_KEY_B64")
+		if key == "" {
+			l.fail("FIREBASE_KEY_B64 is required when AUTH_MODE=firebase")
+		} else if decoded, err := base64.StdEncoding.DecodeString(key); err != nil {
+			l.fail("FIREBASE_KEY_B64 is not valid base64")
+		} else {
+			cfg.Auth.FirebaseCredentials = decoded
+		}
+	case "dev":
+		if err := devauth.Guard(cfg.AppEnv); err != nil {
+			l.fail(err.Error())
+		}
+	default:
+		l.fail(fmt.Sprintf("AUTH_MODE must be firebase or dev, not %q", cfg.Auth.Mode))
+	}
+
+	cfg.RateLimit.Store = l.string("RATE_LIMIT_STORE", "memory")
+	if cfg.RateLimit.Store != "memory" && cfg.RateLimit.Store != "postgres" {
+		l.fail(fmt.Sprintf("RATE_LIMIT_STORE must be memory or postgres, not %q", cfg.RateLimit.Store))
+	}
+	cfg.RateLimit.Limits = map[string]ratelimit.Limit{}
+	for _, d := range defaultRateLimits {
+		key := "RATE_LIMIT_" + strings.ToUpper(d.group)
+		limit, err := ratelimit.ParseLimit(l.string(key, d.spec))
+		if err != nil {
+			l.fail(fmt.Sprintf("%s: %v", key, err))
+		}
+		cfg.RateLimit.Limits[d.group] = limit
+	}
+
+	cfg.SMTP.Host = l.string("SMTP_HOST", "")
+	cfg.SMTP.Port = l.string("SMTP_PORT", "587")
+	cfg.SMTP.Username = l.string("SMTP_USERNAME", "")
+	cfg.SMTP.Password = l.secret("SMTP_PASSWORD")
+	cfg.SMTP.From = l.string("SMTP_FROM", "")
+	cfg.SMTP.OutboxDir = l.string("NOTIFY_OUTBOX_DIR", "")
+	if cfg.SMTP.Host != "" && cfg.SMTP.From == "" {
+		l.fail("SMTP_FROM is required when SMTP_HOST is set")
+	}
+
+	cfg.TracesExporter = l.string("OTEL_TRACES_EXPORTER", "none")
+	switch cfg.TracesExporter {
+	case "none", "otlp", "console", "stdout":
+	default:
+		l.fail(fmt.Sprintf("OTEL_TRACES_EXPORTER must be otlp, console or none, not %q", cfg.TracesExporter))
+	}
+
+	return l.finish(cfg)
+}
+
+// Summary returns every setting with its effective value, secrets redacted,
+// for logging at startup
+func (c *Config) Summary() []slog.Attr {
+	return c.summary
+}
+
+// loader reads settings through lookup, collecting errors and a summary as
+// it goes
+type loader struct {
+	lookup  func(string) (string, bool)
+	errs    []error
+	summary []slog.Attr
+}
+
+// finish returns cfg, or every error found while loading it
+func (l *loader) finish(cfg *Config) (*Config, error) {
+	if len(l.errs) > 0 {
+		return nil, errors.Join(l.errs...)
+	}
+	cfg.summary = l.summary
+	return cfg, nil
+}
+
+func (l *loader) fail(msg string) {
+	l.errs = append(l.errs, errors.New(msg))
+}
+
+func (l *loader) get(key string) (string, bool) {
+	v, ok := l.lookup(key)
+	if !ok || v == "" {
+		return "", false
+	}
+	return v, true
+}
+
+func (l *loader) string(key, def string) string {
+	v, ok := l.get(key)
+	if !ok {
+		v = def
+	}
+	l.summary = append(l.summary, slog.String(key, v))
+	return v
+}
+
+func (l *loader) list(key string) []string {
+	v, _ := l.get(key)
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	l.summary = append(l.summary, slog.Any(key, items))
+	return items
+}
+
+func (l *loader) bool(key string, def bool) bool {
+	b := def
+	if v, ok := l.get(key); ok {
+		var err error
+		if b, err = strconv.ParseBool(v); err != nil {
+			l.fail(key + " must be true or false")
+		}
+	}
+	l.summary = append(l.summary, slog.Bool(key, b))
+	return b
+}
+
+func (l *loader) int(key string, def int) int {
+	n := def
+	if v, ok := l.get(key); ok {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil {
+			l.fail(key + " must be an integer")
+		}
+	}
+	l.summary = append(l.summary, slog.Int(key, n))
+	return n
+}
+
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	d := def
+	if v, ok := l.get(key); ok {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil || d < 0 {
+			l.fail(key + " must be a duration such as 30s")
+		}
+	}
+	l.summary = append(l.summary, slog.String(key, d.String()))
+	return d
+}
+
+// secret reads key, or the file named by key_FILE as mounted by Docker and
+// Kubernetes secrets. Its value is redacted in the summary.
+func (l *loader) secret(key string) string {
+	v := l.secretValue(key)
+	shown := ""
+	if v != "" {
+		shown = logging.Redacted
+	}
+	l.summary = append(l.summary, slog.String(key, shown))
+	return v
+}
+
+// databaseURL is a secret whose summary keeps everything but the password
+func (l *loader) databaseURL(key string) string {
+	v := l.secretValue(key)
+	shown := ""
+	if v != "" {
+		shown = logging.Redacted
+		if u, err := url.Parse(v); err == nil && u.Scheme != "" {
+			if q := u.Query(); q.Has("password") {
+				q.Set("password", "xxxxx")
+				u.RawQuery = q.Encode()
+			}
+			shown = u.Redacted()
+		}
+	}
+	l.summary = append(l.summary, slog.String(key, shown))
+	return v
+}
+
+func (l *loader) secretValue(key string) string {
+	v, ok := l.get(key)
+	path, fromFile := l.get(key + "_FILE")
+	switch {
+	case ok && fromFile:
+		l.fail(fmt.Sprintf("set only one of %s and %s_FILE", key, key))
+		return ""
+	case fromFile:
+		b, err := os.ReadFile(path)
+		if err != nil {
+			l.fail(fmt.Sprintf("%s_FILE: %v", key, err))
+			return ""
+		}
+		return strings.TrimRight(string(b), "\r\n")
+	default:
+		return v
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validEnv is the minimum configuration that loads
+func validEnv() map[string]string {
+	return map[string]string{
+		"ALLOWED_ORIGINS":   "http://localhost:5173, https://fif.example.com",
+		"DATABASE_URL":      "postgres://fif:hunter2@db:5432/fif?sslmode=disable",
+		"FIREBASE_KEY_B64":  base64.StdEncoding.EncodeToString([]byte(`{"type":"service_account"}`)),
+		"SUPABASE_URL":      "https://fif.supabase.co",
+		"SUPABASE_ANON_KEY": "anon-key",
+	}
+}
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := load(lookup(validEnv()), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Assert defaults and parsed values
+	if cfg.Server.Port != "8080" || cfg.Server.ShutdownTimeout != 30*time.Second {
+		t.Errorf("Expected default port and shutdown timeout, got %+v", cfg.Server)
+	}
+	if len(cfg.Server.AllowedOrigins) != 2 || cfg.Server.AllowedOrigins[1] != "https://fif.example.com" {
+		t.Errorf("Expected trimmed origins, got %q", cfg.Server.AllowedOrigins)
+	}
+	if !cfg.Database.MigrateOnStart || cfg.Database.MaxOpenConns != 25 {
+		t.Errorf("Expected default database settings, got %+v", cfg.Database)
+	}
+	if cfg.Auth.Mode != "firebase" || string(cfg.Auth.FirebaseCredentials) != `{"type":"service_account"}` {
+		t.Errorf("Expected decoded firebase credentials, got %+v", cfg.Auth)
+	}
+	if cfg.RateLimit.Limits["api"].Requests != 300 {
+		t.Errorf("Expected default api limit of 300, got %+v", cfg.RateLimit.Limits["api"])
+	}
+	if cfg.RateLimit.Limits["preauth"].Off() {
+		t.Error("Expected requests to be limited by IP before authentication")
+	}
+}
+
+func TestLoad_ReportsAllErrors(t *testing.T) {
+	env := validEnv()
+	delete(env, "ALLOWED_ORIGINS")
+	env["PORT"] = "http"
+	env["AUTH_CACHE_TTL"] = "soon"
+	env["REQUEST_TIMEOUT"] = "2m"
+	env["RATE_LIMIT_API"] = "lots"
+	env["SMTP_HOST"] = "smtp.example.com"
+	delete(env, "SUPABASE_ANON_KEY")
+	env["SUPABASE_URL"] = "fif.supabase.co"
+
+	_, err := load(lookup(env), true)
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	// Assert every problem is in the one error
+	for _, key := range []string{"ALLOWED_ORIGINS", "PORT", "AUTH_CACHE_TTL", "REQUEST_TIMEOUT", "RATE_LIMIT_API", "SMTP_FROM", "SUPABASE_URL", "SUPABASE_ANON_KEY"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error to mention %s, got %v", key, err)
+		}
+	}
+}
+
+func TestLoad_SecretFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database_url")
+	if err := os.WriteFile(path, []byte("postgres://fif:from-file@db/fif\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+
+	env := validEnv()
+	delete(env, "DATABASE_URL")
+	env["DATABASE_URL_FILE"] = path
+
+	cfg, err := load(lookup(env), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Database.URL != "postgres://fif:from-file@db/fif" {
+		t.Errorf("Expected URL from file without the newline, got %q", cfg.Database.URL)
+	}
+
+	// Assert setting both is rejected
+	env["DATABASE_URL"] = "postgres://other"
+	if _, err := load(lookup(env), true); err == nil || !strings.Contains(err.Error(), "DATABASE_URL_FILE") {
+		t.Errorf("Expected error for DATABASE_URL and DATABASE_URL_FILE, got %v", err)
+	}
+}
+
+func TestLoad_DevModeRequiresDevelopment(t *testing.T) {
+	env := validEnv()
+	env["AUTH_MODE"] = "dev"
+
+	if _, err := load(lookup(env), true); err == nil {
+		t.Error("Expected dev auth to be refused without APP_ENV")
+	}
+
+	env["APP_ENV"] = "development"
+	if _, err := load(lookup(env), true); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestSummary_RedactsSecrets(t *testing.T) {
+	env := validEnv()
+	env["SMTP_PASSWORD"] = "smtp-secret"
+
+	cfg, err := load(lookup(env), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	values := map[string]string{}
+	for _, attr := range cfg.Summary() {
+		values[attr.Key] = attr.Value.String()
+		for _, secret := range []string{"hunter2", "smtp-secret", env["FIREBASE_KEY_B64"]} {
+			if strings.Contains(attr.Value.String(), secret) {
+				t.Errorf("Expected %s to be redacted, got %q", attr.Key, attr.Value)
+			}
+		}
+	}
+
+	// Assert non-secret parts are still shown
+	if !strings.Contains(values["DATABASE_URL"], "db:5432/fif") {
+		t.Errorf("Expected database host in summary, got %q", values["DATABASE_URL"])
+	}
+	if values["PORT"] != "8080" {
+		t.Errorf("Expected PORT 8080 in summary, got %q", values["PORT"])
+	}
+}
+
+func TestLoadForCommand_OnlyNeedsDatabase(t *testing.T) {
+	env := map[string]string{"DATABASE_URL": "postgres://fif@db/fif"}
+
+	// Assert serving settings are neither required nor validated
+	cfg, err := load(lookup(env), false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Database.URL != env["DATABASE_URL"] {
+		t.Errorf("Expected database URL, got %q", cfg.Database.URL)
+	}
+
+	if _, err := load(lookup(env), true); err == nil {
+		t.Error("Expected serving without ALLOWED_ORIGINS to fail")
+	}
+}
+
+func TestLoad_CSPConnectsToConfiguredOrigins(t *testing.T) {
+	env := validEnv()
+	env["FRONTEND_API_URL"] = "https://api.fif.example.com/api"
+	env["SUPABASE_URL"] = "https://auth.fif.example.com"
+
+	cfg, err := load(lookup(env), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Assert connect-src follows the API and Supabase URLs
+	expected := "connect-src 'self' https://api.fif.example.com https://auth.fif.example.com wss://auth.fif.example.com;"
+	if !strings.Contains(cfg.Security.CSP, expected) {
+		t.Errorf("Expected %q in %q", expected, cfg.Security.CSP)
+	}
+	if !strings.Contains(cfg.Security.CSP, "'nonce-{nonce}'") {
+		t.Errorf("Expected a nonce in %q", cfg.Security.CSP)
+	}
+
+	// Assert a relative API URL is left to 'self'
+	delete(env, "FRONTEND_API_URL")
+	cfg, err = load(lookup(env), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(cfg.Security.CSP, "connect-src 'self' https://auth.fif.example.com wss://") {
+		t.Errorf("Expected only Supabase besides 'self', got %q", cfg.Security.CSP)
+	}
+}
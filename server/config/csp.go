@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fif/middleware"
+	"net/url"
+	"strings"
+)
+
+// defaultCSP allows the SPA's own scripts, by nonce, and connections to the
+// API and Supabase wherever the web app is told to find them. Mantine injects
+// <style> tags at runtime, so styles may be inline.
+func defaultCSP(frontend FrontendConfig) string {
+	connect := []string{"'self'"}
+	if origin, ok := originOf(frontend.APIURL); ok {
+		connect = append(connect, origin)
+	}
+	if origin, ok := originOf(frontend.SupabaseURL); ok {
+		// Supabase realtime uses a websocket to the same host
+		connect = append(connect, origin, "ws"+strings.TrimPrefix(origin, "http"))
+	}
+
+	return strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-" + middleware.NonceToken + "'",
+		"style-src 'self' 'unsafe-inline'",
+		"img-src 'self' data: https:",
+		"font-src 'self' data:",
+		"connect-src " + strings.Join(connect, " "),
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+	}, "; ")
+}
+
+// originOf returns the scheme and host of an absolute http or https URL.
+// Relative URLs such as /api are covered by 'self'.
+func originOf(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", false
+	}
+	return u.Scheme + "://" + u.Host, true
+}
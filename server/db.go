@@ -1,27 +1,72 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"fif/config"
+	"fif/tracing"
 	"fmt"
-	"os"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
-func InitDB() (*sql.DB, error) {
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		return nil, fmt.Errorf("DATABASE_URL is not set")
-	}
+// maxConnectBackoff caps the wait between connection attempts at startup
+const maxConnectBackoff = 10 * time.Second
 
-	db, err := sql.Open("postgres", dsn)
+// InitDB opens the connection pool and waits for Postgres to accept
+// connections, retrying with exponential backoff for up to ConnectTimeout so
+// the server can start alongside the database
+func InitDB(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	db, err := tracing.OpenDB("postgres", withStatementTimeout(cfg.URL, cfg.StatementTimeout))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+
+	backoff := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return db, nil
+		}
+
+		slog.Warn("Database not ready", "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("failed to ping database after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// withStatementTimeout adds statement_timeout to the DSN unless it already
+// sets one. lib/pq passes parameters it does not know to Postgres as session
+// settings, in both URL and key=value DSNs.
+func withStatementTimeout(dsn string, timeout time.Duration) string {
+	if timeout <= 0 || strings.Contains(dsn, "statement_timeout") {
+		return dsn
 	}
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
 
-	return db, nil
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("statement_timeout", ms)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " statement_timeout=" + ms
 }
@@ -0,0 +1,188 @@
+package demo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fif/portfolio"
+	"fif/tax"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultYears is how many complete income years of history to generate
+const DefaultYears = 3
+
+// DefaultSeed makes every demo portfolio the same unless asked otherwise
+const DefaultSeed = 2024
+
+// ErrRealHoldings is returned when the user holds anything but demo instruments
+var ErrRealHoldings = errors.New("user has holdings that are not demo instruments")
+
+// ErrNoRates is returned when no day in the range has a stored rate for every
+// demo currency
+var ErrNoRates = errors.New("no stored FX rates for the demo currencies")
+
+// Options controls what Generate creates
+type Options struct {
+	// Years of complete income years before the current one
+	Years int
+	// Seed picks the price paths; the same seed gives the same history
+	Seed uint64
+	// Now is the last day generated, taken as the date in New Zealand
+	Now time.Time
+}
+
+// Summary describes what Generate wrote
+type Summary struct {
+	From      time.Time
+	To        time.Time
+	Holdings  int
+	Prices    int
+	Dividends int
+	Snapshots int
+}
+
+// Generate replaces a user's portfolio with the demo portfolio and its daily
+// history. The history starts on 31 March before the first income year, or on
+// the first day after it with stored FX rates, so running it again with the
+// same seed keeps the earlier days and adds the days since. Everything is
+// rewritten in one transaction, so readers see the old history until it
+// commits and a failure leaves it as it was. FX rates are shared with real
+// portfolios, so the demo only reads them and never writes its own.
+func Generate(ctx context.Context, db *sql.DB, userID string, opts Options) (Summary, error) {
+	if opts.Years <= 0 {
+		opts.Years = DefaultYears
+	}
+	if opts.Seed == 0 {
+		opts.Seed = DefaultSeed
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	end := tax.Date(opts.Now)
+	start := tax.YearEnd(tax.IncomeYear(end) - opts.Years - 1)
+
+	known, err := loadRates(ctx, db, currenciesOf(Instruments))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	d := simulate(start, end, opts.Seed, known)
+	if len(d.valuations) == 0 {
+		return Summary{}, ErrNoRates
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Summary{}, err
+	}
+	defer tx.Rollback()
+
+	var real int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT count(*) FROM holdings WHERE user_id = $1 AND symbol NOT LIKE $2
+	`, userID, SymbolPrefix+"%").Scan(&real); err != nil {
+		return Summary{}, fmt.Errorf("failed to check holdings: %w", err)
+	}
+	if real > 0 {
+		return Summary{}, ErrRealHoldings
+	}
+
+	if err := saveMarketData(ctx, tx, d); err != nil {
+		return Summary{}, err
+	}
+	if err := portfolio.ImportHoldingsTx(ctx, tx, userID, d.holdings, true); err != nil {
+		return Summary{}, fmt.Errorf("failed to save holdings: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM portfolio_snapshots WHERE user_id = $1
+	`, userID); err != nil {
+		return Summary{}, fmt.Errorf("failed to clear snapshots: %w", err)
+	}
+	for _, v := range d.valuations {
+		if err := portfolio.SaveSnapshotTx(ctx, tx, userID, v); err != nil {
+			return Summary{}, fmt.Errorf("failed to snapshot %s: %w", v.Date, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Summary{}, fmt.Errorf("failed to commit demo portfolio: %w", err)
+	}
+
+	return Summary{
+		From:      d.prices[0].Date,
+		To:        end,
+		Holdings:  len(d.holdings),
+		Prices:    len(d.prices),
+		Dividends: d.dividends,
+		Snapshots: len(d.valuations),
+	}, nil
+}
+
+// loadRates returns every stored rate for the currencies, oldest first
+func loadRates(ctx context.Context, db *sql.DB, currencies []string) (map[string][]portfolio.Quote, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT currency, rate_date, rate
+		FROM fx_rates
+		WHERE currency = ANY($1)
+		ORDER BY currency, rate_date
+	`, pq.Array(currencies))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := map[string][]portfolio.Quote{}
+	for rows.Next() {
+		var currency string
+		var q portfolio.Quote
+		if err := rows.Scan(&currency, &q.Date, &q.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan rate: %w", err)
+		}
+		q.Date = q.Date.UTC()
+		rates[currency] = append(rates[currency], q)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate rates: %w", err)
+	}
+	return rates, nil
+}
+
+// saveMarketData writes the demo instruments and their prices
+func saveMarketData(ctx context.Context, tx *sql.Tx, d dataset) error {
+	for _, in := range Instruments {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO instruments (symbol, name, currency, exchange)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (symbol) DO UPDATE
+				SET name = EXCLUDED.name, currency = EXCLUDED.currency, exchange = EXCLUDED.exchange
+		`, in.Symbol, in.Name, in.Currency, in.Exchange); err != nil {
+			return fmt.Errorf("failed to save instrument %s: %w", in.Symbol, err)
+		}
+	}
+
+	symbols, dates, values := columns(d.prices)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO prices (symbol, price_date, close)
+		SELECT * FROM unnest($1::text[], $2::date[], $3::numeric[])
+		ON CONFLICT (symbol, price_date) DO UPDATE SET close = EXCLUDED.close
+	`, pq.Array(symbols), pq.Array(dates), pq.Array(values)); err != nil {
+		return fmt.Errorf("failed to save prices: %w", err)
+	}
+	return nil
+}
+
+// columns splits rows into arrays for unnest
+func columns(rows []row) (keys, dates []string, values []float64) {
+	for _, r := range rows {
+		keys = append(keys, r.Key)
+		dates = append(dates, r.Date.Format(portfolio.DateLayout))
+		values = append(values, r.Value)
+	}
+	return keys, dates, values
+}
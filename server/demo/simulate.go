@@ -0,0 +1,221 @@
+// Package demo generates a believable portfolio history for showing the
+// product without real data
+package demo
+
+import (
+	"fif/portfolio"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// SymbolPrefix marks the fictional instruments the generator owns. Prices are
+// shared by every user, so the demo never writes prices for real symbols.
+const SymbolPrefix = "DEMO-"
+
+// tradingDays is the number of price days in a year
+const tradingDays = 252
+
+// Instrument is a fictional security and the parameters of its price path
+type Instrument struct {
+	Symbol   string
+	Name     string
+	Currency string
+	Exchange string
+	// Start is the price on the first day
+	Start float64
+	// Drift and Volatility are annual
+	Drift      float64
+	Volatility float64
+	// Yield is the annual dividend yield, paid quarterly
+	Yield float64
+	// Weight is the share of each contribution invested in the instrument
+	Weight float64
+}
+
+// Instruments is the demo portfolio
+var Instruments = []Instrument{
+	{Symbol: "DEMO-WORLD", Name: "Global Equity ETF", Currency: "USD", Exchange: "Demo", Start: 80, Drift: 0.07, Volatility: 0.15, Yield: 0.018, Weight: 0.35},
+	{Symbol: "DEMO-US500", Name: "US 500 ETF", Currency: "USD", Exchange: "Demo", Start: 300, Drift: 0.09, Volatility: 0.17, Yield: 0.014, Weight: 0.25},
+	{Symbol: "DEMO-TECH", Name: "Technology Inc.", Currency: "USD", Exchange: "Demo", Start: 150, Drift: 0.12, Volatility: 0.30, Yield: 0.005, Weight: 0.10},
+	{Symbol: "DEMO-ASX", Name: "Australian Shares ETF", Currency: "AUD", Exchange: "Demo", Start: 60, Drift: 0.06, Volatility: 0.14, Yield: 0.04, Weight: 0.20},
+	{Symbol: "DEMO-BOND", Name: "Global Bond ETF", Currency: "USD", Exchange: "Demo", Start: 50, Drift: 0.02, Volatility: 0.05, Yield: 0.03, Weight: 0.10},
+}
+
+// Contributions in NZD: a lump sum on the first day, then one each month
+const (
+	initialInvestment   = 20000.0
+	monthlyContribution = 1000.0
+)
+
+// row is one generated price
+type row struct {
+	Key   string
+	Date  time.Time
+	Value float64
+}
+
+// dataset is a simulated history
+type dataset struct {
+	prices     []row
+	holdings   []portfolio.Holding
+	valuations []portfolio.Valuation
+	dividends  int
+}
+
+// simulate builds a history from start to end inclusive. Rates are shared
+// with real portfolios, so none are made up: the known rates, sorted by date,
+// are carried forward like a valuation would, and days before every currency
+// has one are skipped. Every instrument draws from its own stream, so
+// simulating to a later end leaves the earlier days unchanged.
+func simulate(start, end time.Time, seed uint64, known map[string][]portfolio.Quote) dataset {
+	var d dataset
+
+	priceRNG := make([]*rand.Rand, len(Instruments))
+	prices := map[string]portfolio.Quote{}
+	holdings := make([]portfolio.Holding, len(Instruments))
+	for i, in := range Instruments {
+		priceRNG[i] = rand.New(rand.NewPCG(seed, uint64(i)))
+		holdings[i] = portfolio.Holding{Name: in.Name, Symbol: in.Symbol, Currency: in.Currency, CostNZD: new(float64)}
+	}
+
+	currencies := currenciesOf(Instruments)
+	rates := map[string]portfolio.Quote{}
+
+	dt := 1.0 / tradingDays
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if !ratesOn(rates, known, currencies, day) {
+			continue
+		}
+
+		first := len(d.valuations) == 0
+		// Markets are closed at weekends, but the first day always has a
+		// price so the opening valuation is complete
+		if !first && isWeekend(day) {
+			d.valuations = append(d.valuations, portfolio.Value(day, holdings, prices, rates))
+			continue
+		}
+
+		for i, in := range Instruments {
+			z := priceRNG[i].NormFloat64()
+			p := in.Start
+			if !first {
+				p = prices[in.Symbol].Value * math.Exp((in.Drift-in.Volatility*in.Volatility/2)*dt+in.Volatility*math.Sqrt(dt)*z)
+			}
+			p = round(p, 4)
+			prices[in.Symbol] = portfolio.Quote{Value: p, Date: day}
+			d.prices = append(d.prices, row{Key: in.Symbol, Date: day, Value: p})
+		}
+
+		switch {
+		case first:
+			invest(holdings, prices, rates, initialInvestment)
+		case isFirstWeekday(day):
+			invest(holdings, prices, rates, monthlyContribution)
+		}
+		if isDividendDay(day) {
+			d.dividends += reinvestDividends(holdings, prices, rates)
+		}
+
+		d.valuations = append(d.valuations, portfolio.Value(day, holdings, prices, rates))
+	}
+
+	d.holdings = holdings
+	return d
+}
+
+// invest buys each instrument with its share of nzd
+func invest(holdings []portfolio.Holding, prices, rates map[string]portfolio.Quote, nzd float64) {
+	for i, in := range Instruments {
+		rate, ok := rates[in.Currency]
+		if !ok {
+			continue
+		}
+		amount := round(nzd*in.Weight*rate.Value, 2)
+		holdings[i].Quantity = round(holdings[i].Quantity+amount/prices[in.Symbol].Value, 8)
+		holdings[i].Cost = round(holdings[i].Cost+amount, 2)
+		*holdings[i].CostNZD = round(*holdings[i].CostNZD+nzd*in.Weight, 2)
+	}
+}
+
+// reinvestDividends pays a quarter of each instrument's yield and buys more of
+// it, returning the number of dividends paid
+func reinvestDividends(holdings []portfolio.Holding, prices, rates map[string]portfolio.Quote) int {
+	paid := 0
+	for i, in := range Instruments {
+		rate, ok := rates[in.Currency]
+		if in.Yield == 0 || holdings[i].Quantity == 0 || !ok {
+			continue
+		}
+		price := prices[in.Symbol].Value
+		amount := round(holdings[i].Quantity*price*in.Yield/4, 2)
+		holdings[i].Quantity = round(holdings[i].Quantity+amount/price, 8)
+		holdings[i].Cost = round(holdings[i].Cost+amount, 2)
+		*holdings[i].CostNZD = round(*holdings[i].CostNZD+amount/rate.Value, 2)
+		paid++
+	}
+	return paid
+}
+
+// ratesOn sets rates to the latest known rate on or before day for each
+// currency, reporting whether every currency has one
+func ratesOn(rates map[string]portfolio.Quote, known map[string][]portfolio.Quote, currencies []string, day time.Time) bool {
+	all := true
+	for _, c := range currencies {
+		q, ok := latestOnOrBefore(known[c], day)
+		if !ok {
+			all = false
+			continue
+		}
+		rates[c] = q
+	}
+	return all
+}
+
+// latestOnOrBefore returns the last quote dated on or before day
+func latestOnOrBefore(series []portfolio.Quote, day time.Time) (portfolio.Quote, bool) {
+	var latest portfolio.Quote
+	found := false
+	for _, q := range series {
+		if q.Date.After(day) {
+			break
+		}
+		latest, found = q, true
+	}
+	return latest, found
+}
+
+func currenciesOf(instruments []Instrument) []string {
+	seen := map[string]bool{}
+	currencies := []string{}
+	for _, in := range instruments {
+		if in.Currency != portfolio.BaseCurrency && !seen[in.Currency] {
+			seen[in.Currency] = true
+			currencies = append(currencies, in.Currency)
+		}
+	}
+	return currencies
+}
+
+func isWeekend(day time.Time) bool {
+	return day.Weekday() == time.Saturday || day.Weekday() == time.Sunday
+}
+
+// isFirstWeekday reports whether day is the first weekday of its month
+func isFirstWeekday(day time.Time) bool {
+	return !isWeekend(day) && day.Day() <= 3 && (day.Day() == 1 || day.Weekday() == time.Monday)
+}
+
+// isDividendDay reports whether day is the first weekday on or after the 15th
+// of the last month of a quarter
+func isDividendDay(day time.Time) bool {
+	if day.Month()%3 != 0 || isWeekend(day) {
+		return false
+	}
+	return day.Day() == 15 || (day.Day() >= 16 && day.Day() <= 17 && day.Weekday() == time.Monday)
+}
+
+func round(f float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(f*p) / p
+}
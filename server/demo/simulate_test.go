@@ -0,0 +1,95 @@
+package demo
+
+import (
+	"fif/portfolio"
+	"testing"
+	"time"
+)
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(portfolio.DateLayout, s)
+	if err != nil {
+		t.Fatalf("Failed to parse date %q: %v", s, err)
+	}
+	return d
+}
+
+// ratesFrom gives every demo currency one known rate on date
+func ratesFrom(t *testing.T, date string) map[string][]portfolio.Quote {
+	return map[string][]portfolio.Quote{
+		"USD": {{Value: 0.6, Date: day(t, date)}},
+		"AUD": {{Value: 0.9, Date: day(t, date)}},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	start, end := day(t, "2023-03-31"), day(t, "2024-03-31")
+
+	d := simulate(start, end, DefaultSeed, ratesFrom(t, "2023-03-31"))
+
+	// Assert every day is valued and the portfolio grew
+	if len(d.valuations) != 367 {
+		t.Fatalf("Expected 367 valuations, got %d", len(d.valuations))
+	}
+	first, last := d.valuations[0], d.valuations[len(d.valuations)-1]
+	if !first.Complete || !last.Complete {
+		t.Errorf("Expected complete valuations, got %v and %v", first.Complete, last.Complete)
+	}
+	if last.CostNZD <= first.CostNZD {
+		t.Errorf("Expected contributions to add cost, got %.2f then %.2f", first.CostNZD, last.CostNZD)
+	}
+	if d.dividends != 4*len(Instruments) {
+		t.Errorf("Expected a dividend per instrument each quarter, got %d", d.dividends)
+	}
+	for _, p := range d.prices {
+		if p.Date != start && isWeekend(p.Date) {
+			t.Fatalf("Expected no weekend prices, got %s on %s", p.Key, p.Date.Format(portfolio.DateLayout))
+		}
+	}
+}
+
+func TestSimulate_ExtendingKeepsHistory(t *testing.T) {
+	start := day(t, "2023-03-31")
+
+	known := ratesFrom(t, "2023-03-31")
+	short := simulate(start, day(t, "2023-06-30"), DefaultSeed, known)
+	long := simulate(start, day(t, "2023-09-30"), DefaultSeed, known)
+
+	// Assert a later end only adds days
+	for i, p := range short.prices {
+		if long.prices[i] != p {
+			t.Fatalf("Expected price %d to be unchanged, got %+v and %+v", i, p, long.prices[i])
+		}
+	}
+	if short.valuations[len(short.valuations)-1].MarketValueNZD != long.valuations[len(short.valuations)-1].MarketValueNZD {
+		t.Error("Expected the same valuation on the shared days")
+	}
+}
+
+func TestSimulate_OnlyUsesKnownRates(t *testing.T) {
+	start, end := day(t, "2023-03-31"), day(t, "2023-04-30")
+	known := map[string][]portfolio.Quote{
+		"USD": {
+			{Value: 0.6, Date: day(t, "2023-03-31")},
+			{Value: 0.61, Date: day(t, "2023-04-14")},
+		},
+		"AUD": {{Value: 0.9, Date: day(t, "2023-04-03")}},
+	}
+
+	d := simulate(start, end, DefaultSeed, known)
+
+	// Assert the history waits for every currency to have a rate
+	if len(d.valuations) != 28 || d.valuations[0].Date != "2023-04-03" {
+		t.Fatalf("Expected 28 valuations from 2023-04-03, got %d from %s", len(d.valuations), d.valuations[0].Date)
+	}
+
+	// Assert known rates are carried forward, including past the last one
+	for i, expected := range map[int]float64{10: 0.6, 11: 0.61, 27: 0.61} {
+		v := d.valuations[i]
+		holding := v.Holdings[0]
+		if holding.FXRate == nil || *holding.FXRate != expected {
+			t.Errorf("Expected USD rate %v on %s, got %v", expected, v.Date, holding.FXRate)
+		}
+	}
+}
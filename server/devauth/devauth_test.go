@@ -0,0 +1,111 @@
+package devauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fif/middleware"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestVerifier(t *testing.T) *Verifier {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	return New(key)
+}
+
+func TestMintAndVerify(t *testing.T) {
+	// Create a token with an email and a role
+	v := newTestVerifier(t)
+	raw, err := v.Mint("alice", "alice@example.com", time.Hour, map[string]interface{}{"roles": []string{"admin"}, "sub": "mallory"})
+	if err != nil {
+		t.Fatalf("Mint failed: %v", err)
+	}
+
+	token, err := v.VerifyIDToken(context.Background(), raw)
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+
+	// Assert the claims survive and reserved claims cannot be overridden
+	if token.UID != "alice" {
+		t.Errorf("Expected UID alice, got %s", token.UID)
+	}
+	if token.Claims["email"] != "alice@example.com" {
+		t.Errorf("Expected email claim, got %v", token.Claims["email"])
+	}
+	if roles := middleware.TokenRoles(token); len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("Expected admin role, got %v", roles)
+	}
+}
+
+func TestVerifyIDToken_Failures(t *testing.T) {
+	v := newTestVerifier(t)
+	other := newTestVerifier(t)
+
+	expired, _ := v.Mint("alice", "", -time.Minute, nil)
+	foreign, _ := other.Mint("alice", "", time.Hour, nil)
+
+	testCases := []struct {
+		name           string
+		token          string
+		expectedReason string
+	}{
+		{name: "Expired", token: expired, expectedReason: middleware.ReasonExpired},
+		{name: "OtherKey", token: foreign, expectedReason: middleware.ReasonInvalid},
+		{name: "Garbage", token: "not-a-jwt", expectedReason: middleware.ReasonMalformed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.VerifyIDToken(context.Background(), tc.token)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if reason := middleware.ClassifyAuthError(err); reason != tc.expectedReason {
+				t.Errorf("Expected reason %s, got %s (%v)", tc.expectedReason, reason, err)
+			}
+		})
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	// Create a key, then load it again from the same file
+	path := filepath.Join(t.TempDir(), "keys", "dev.pem")
+	created, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	loaded, err := LoadOrCreateKey(path)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+
+	// Assert the same key comes back
+	if !created.Equal(loaded) {
+		t.Error("Expected the saved key to be reloaded")
+	}
+}
+
+func TestGuard(t *testing.T) {
+	testCases := []struct {
+		env     string
+		allowed bool
+	}{
+		{"development", true},
+		{"test", true},
+		{"production", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		if err := Guard(tc.env); (err == nil) != tc.allowed {
+			t.Errorf("Guard() with APP_ENV=%q returned %v", tc.env, err)
+		}
+	}
+}
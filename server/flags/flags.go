@@ -0,0 +1,145 @@
+// Package flags decides which features are on for a user. Flags are defined
+// in a JSON file and may be overridden in the feature_flags table, so a
+// rollout can be widened without a deploy.
+package flags
+
+import (
+	"encoding/json"
+	"errors"
+	"fif/middleware"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// TaxPage shows the Tax page in the web app
+const TaxPage = "tax-page"
+
+// namePattern is what flag names look like
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Flag decides who a feature is on for. It is on when any of Enabled, Users
+// or Percentage lets the user in.
+type Flag struct {
+	Description string `json:"description,omitempty"`
+	// Enabled turns the feature on for everyone
+	Enabled bool `json:"enabled"`
+	// Percentage of signed-in users, 0 to 100, the feature is on for. A user
+	// stays in the same bucket as the percentage grows.
+	Percentage int `json:"percentage"`
+	// Users the feature is always on for
+	Users []string `json:"users"`
+}
+
+// On reports whether the flag called name is on for userID, which is empty
+// for anonymous requests
+func (f Flag) On(name, userID string) bool {
+	if f.Enabled {
+		return true
+	}
+	if userID == "" {
+		return false
+	}
+	if slices.Contains(f.Users, userID) {
+		return true
+	}
+	return bucket(name, userID) < f.Percentage
+}
+
+// Validate checks the percentage
+func (f Flag) Validate() error {
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100, not %d", f.Percentage)
+	}
+	return nil
+}
+
+// ValidName reports whether name can be used for a flag
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// UserID is the signed-in user flags are evaluated for, or "" when there is
+// none. Demo visitors share one user, so they count as anonymous.
+func UserID(r *http.Request) string {
+	token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+	if !ok || token == nil || middleware.IsDemo(token) {
+		return ""
+	}
+	return token.UID
+}
+
+// bucket places a user in 0-99 for a flag. The name is part of the hash so
+// each flag reaches a different slice of users.
+func bucket(name, userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return int(h.Sum32() % 100)
+}
+
+// Set is a collection of flags by name
+type Set map[string]Flag
+
+// Evaluate returns whether each flag is on for userID
+func (s Set) Evaluate(userID string) map[string]bool {
+	result := make(map[string]bool, len(s))
+	for name, f := range s {
+		result[name] = f.On(name, userID)
+	}
+	return result
+}
+
+// Names returns the flag names in order
+func (s Set) Names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse reads a JSON object of flags by name, rejecting unknown fields and
+// invalid flags
+func Parse(r io.Reader) (Set, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	set := Set{}
+	if err := dec.Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid flags: %w", err)
+	}
+
+	var errs []error
+	for _, name := range set.Names() {
+		if !ValidName(name) {
+			errs = append(errs, fmt.Errorf("%q is not a valid flag name", name))
+		}
+		if err := set[name].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// ParseFile reads flags from a JSON file
+func ParseFile(path string) (Set, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
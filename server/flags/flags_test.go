@@ -0,0 +1,172 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlag_On(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flag     Flag
+		userID   string
+		expected bool
+	}{
+		{name: "Off", flag: Flag{}, userID: "u1", expected: false},
+		{name: "Enabled", flag: Flag{Enabled: true}, userID: "", expected: true},
+		{name: "TargetedUser", flag: Flag{Users: []string{"u1"}}, userID: "u1", expected: true},
+		{name: "OtherUser", flag: Flag{Users: []string{"u1"}}, userID: "u2", expected: false},
+		{name: "FullRollout", flag: Flag{Percentage: 100}, userID: "u2", expected: true},
+		{name: "AnonymousInRollout", flag: Flag{Percentage: 100}, userID: "", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.flag.On("cv-method", tc.userID); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestFlag_PercentageIsStable(t *testing.T) {
+	users := make([]string, 1000)
+	for i := range users {
+		users[i] = fmt.Sprintf("user-%d", i)
+	}
+
+	// Assert roughly the right share is in, and widening keeps everyone in
+	on := 0
+	for _, u := range users {
+		if (Flag{Percentage: 10}).On("broker-imports", u) {
+			on++
+			if !(Flag{Percentage: 50}).On("broker-imports", u) {
+				t.Errorf("Expected %s to stay in when the rollout grows", u)
+			}
+		}
+	}
+	if on < 60 || on > 140 {
+		t.Errorf("Expected about 100 of 1000 users at 10%%, got %d", on)
+	}
+}
+
+func TestParse(t *testing.T) {
+	set, err := Parse(strings.NewReader(`{"tax-page": {"users": ["u1"]}, "cv-method": {"percentage": 20}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(set) != 2 || set["cv-method"].Percentage != 20 {
+		t.Errorf("Unexpected flags %+v", set)
+	}
+
+	// Assert every problem is reported
+	_, err = Parse(strings.NewReader(`{"Bad Name": {}, "cv-method": {"percentage": 150}}`))
+	if err == nil || !strings.Contains(err.Error(), "Bad Name") || !strings.Contains(err.Error(), "cv-method") {
+		t.Errorf("Expected errors for both flags, got %v", err)
+	}
+	if _, err := Parse(strings.NewReader(`{"cv-method": {"percent": 20}}`)); err == nil {
+		t.Error("Expected unknown fields to be rejected")
+	}
+}
+
+func TestStore_OverridesAndRefresh(t *testing.T) {
+	// Create a store whose database overrides the file and then fails
+	now := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	store := NewStore(nil, Set{TaxPage: {}, "cv-method": {Enabled: true}}, time.Minute)
+	store.now = func() time.Time { return now }
+	loads := make(chan struct{}, 10)
+	store.load = func(ctx context.Context) (Set, error) {
+		loads <- struct{}{}
+		if len(loads) > 1 {
+			return nil, errors.New("database down")
+		}
+		return Set{TaxPage: {Users: []string{"u1"}}}, nil
+	}
+
+	ctx := context.Background()
+
+	// Assert the override replaces the file's flag
+	if !store.Enabled(ctx, TaxPage, "u1") || !store.Enabled(ctx, "cv-method", "u1") {
+		t.Errorf("Expected both flags on, got %v", store.Evaluate(ctx, "u1"))
+	}
+	if store.Enabled(ctx, "unknown", "u1") {
+		t.Error("Expected unknown flags to be off")
+	}
+	if len(loads) != 1 {
+		t.Errorf("Expected one load within the refresh interval, got %d", len(loads))
+	}
+
+	// Assert a failed refresh keeps the last good overrides
+	now = now.Add(time.Minute)
+	store.Enabled(ctx, TaxPage, "u1")
+	waitForRefresh(t, store)
+	if len(loads) != 2 {
+		t.Errorf("Expected a refresh after the interval, got %d loads", len(loads))
+	}
+	if !store.Enabled(ctx, TaxPage, "u1") {
+		t.Error("Expected the override to survive a failed refresh")
+	}
+}
+
+func TestStore_RefreshDoesNotBlockOrUseRequestContext(t *testing.T) {
+	// Create a store whose second load is slow
+	now := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	store := NewStore(nil, Set{}, time.Minute)
+	store.now = func() time.Time { return now }
+	release := make(chan struct{})
+	loads := 0
+	store.load = func(ctx context.Context) (Set, error) {
+		loads++
+		if loads > 1 {
+			<-release
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return Set{TaxPage: {Percentage: 100}}, nil
+	}
+
+	// Assert the first load is not cut short by a cancelled request
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	store.Enabled(cancelled, TaxPage, "u1")
+	waitForRefresh(t, store)
+	if !store.Enabled(context.Background(), TaxPage, "u1") {
+		t.Error("Expected the overrides loaded despite the cancelled request")
+	}
+
+	// Assert callers are served the last set while a slow refresh runs
+	now = now.Add(time.Minute)
+	done := make(chan bool)
+	go func() { done <- store.Enabled(context.Background(), TaxPage, "u1") }()
+	select {
+	case on := <-done:
+		if !on {
+			t.Error("Expected the last overrides during the refresh")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the check not to wait for the refresh")
+	}
+	close(release)
+	waitForRefresh(t, store)
+}
+
+// waitForRefresh waits for a refresh in flight to finish
+func waitForRefresh(t *testing.T, s *Store) {
+	t.Helper()
+	s.mu.Lock()
+	loading := s.loading
+	s.mu.Unlock()
+	if loading == nil {
+		return
+	}
+	select {
+	case <-loading:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the refresh")
+	}
+}
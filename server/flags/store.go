@@ -0,0 +1,232 @@
+package flags
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Where a flag's definition came from
+const (
+	SourceFile     = "file"
+	SourceDatabase = "database"
+)
+
+// Definition is a flag as the admin API shows it
+type Definition struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Flag
+}
+
+// loadTimeout bounds a refresh of the overrides
+const loadTimeout = 5 * time.Second
+
+// Store combines the flags file with overrides from the database. Overrides
+// are re-read at most once per refresh interval, in the background while the
+// last set is served; if that fails the last good set stays in use.
+type Store struct {
+	db      *sql.DB
+	file    Set
+	refresh time.Duration
+	now     func() time.Time
+	// load reads the overrides; a seam for tests
+	load func(ctx context.Context) (Set, error)
+
+	mu        sync.Mutex
+	overrides Set
+	// loadedAt is zero until the first load and after a change
+	loadedAt time.Time
+	// loading is closed when the load in flight finishes, nil if none is
+	loading chan struct{}
+	// version counts changes, so a load that started before one is not
+	// taken as current
+	version int
+}
+
+// NewStore creates a Store over the flags from the file. With a nil db only
+// the file is used.
+func NewStore(db *sql.DB, file Set, refresh time.Duration) *Store {
+	if file == nil {
+		file = Set{}
+	}
+	s := &Store{db: db, file: file, refresh: refresh, now: time.Now}
+	s.load = s.loadOverrides
+	return s
+}
+
+// Enabled reports whether the flag called name is on for userID. Unknown
+// flags are off.
+func (s *Store) Enabled(ctx context.Context, name, userID string) bool {
+	f, ok := s.current(ctx)[name]
+	return ok && f.On(name, userID)
+}
+
+// Evaluate returns whether each flag is on for userID
+func (s *Store) Evaluate(ctx context.Context, userID string) map[string]bool {
+	return s.current(ctx).Evaluate(userID)
+}
+
+// List returns every flag with where its definition came from
+func (s *Store) List(ctx context.Context) ([]Definition, error) {
+	overrides, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := []Definition{}
+	for _, name := range merge(s.file, overrides).Names() {
+		if f, ok := overrides[name]; ok {
+			defs = append(defs, Definition{Name: name, Source: SourceDatabase, Flag: f})
+		} else {
+			defs = append(defs, Definition{Name: name, Source: SourceFile, Flag: s.file[name]})
+		}
+	}
+	return defs, nil
+}
+
+// SetOverride stores a flag in the database, replacing the file's definition
+func (s *Store) SetOverride(ctx context.Context, name string, f Flag) error {
+	if s.db == nil {
+		return errors.New("flag overrides need a database")
+	}
+	if f.Users == nil {
+		f.Users = []string{}
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO feature_flags (name, description, enabled, percentage, users)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE
+			SET description = EXCLUDED.description, enabled = EXCLUDED.enabled,
+				percentage = EXCLUDED.percentage, users = EXCLUDED.users
+	`, name, f.Description, f.Enabled, f.Percentage, pq.Array(f.Users)); err != nil {
+		return fmt.Errorf("failed to save flag: %w", err)
+	}
+	s.invalidate()
+	return nil
+}
+
+// DeleteOverride removes a flag's override so the file applies again,
+// returning false if there was none
+func (s *Store) DeleteOverride(ctx context.Context, name string) (bool, error) {
+	if s.db == nil {
+		return false, nil
+	}
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM feature_flags WHERE name = $1`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete flag: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete flag: %w", err)
+	}
+	s.invalidate()
+	return affected > 0, nil
+}
+
+// current returns the file's flags with the overrides applied, starting a
+// refresh when they are older than the refresh interval. Only a caller with no
+// current overrides, before the first load or after a change, waits for it,
+// and no longer than ctx allows.
+func (s *Store) current(ctx context.Context) Set {
+	s.mu.Lock()
+	if s.loading == nil && (s.loadedAt.IsZero() || s.now().Sub(s.loadedAt) >= s.refresh) {
+		s.loading = make(chan struct{})
+		go s.reload(s.loading, s.version)
+	}
+	loading, wait := s.loading, s.loadedAt.IsZero()
+	s.mu.Unlock()
+
+	if wait && loading != nil {
+		select {
+		case <-loading:
+		case <-ctx.Done():
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return merge(s.file, s.overrides)
+}
+
+// reload reads the overrides without holding the lock, then swaps them in.
+// It does not use a request's context, so a cancelled request cannot fail it.
+func (s *Store) reload(done chan struct{}, version int) {
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	overrides, err := s.load(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer close(done)
+	s.loading = nil
+
+	if err != nil {
+		slog.Error("Error loading feature flag overrides", "error", err)
+	} else {
+		s.overrides = overrides
+	}
+	// A change made during the load may be missing from it, so leave the
+	// next call to load again. Otherwise wait a full interval, even after an
+	// error.
+	if s.version == version {
+		s.loadedAt = s.now()
+	}
+}
+
+// invalidate makes the next check re-read the overrides
+func (s *Store) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.loadedAt = time.Time{}
+}
+
+func (s *Store) loadOverrides(ctx context.Context) (Set, error) {
+	if s.db == nil {
+		return Set{}, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT name, description, enabled, percentage, users
+		FROM feature_flags
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query flags: %w", err)
+	}
+	defer rows.Close()
+
+	set := Set{}
+	for rows.Next() {
+		var name string
+		var f Flag
+		if err := rows.Scan(&name, &f.Description, &f.Enabled, &f.Percentage, pq.Array(&f.Users)); err != nil {
+			return nil, fmt.Errorf("failed to scan flag: %w", err)
+		}
+		set[name] = f
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate flags: %w", err)
+	}
+	return set, nil
+}
+
+// merge returns base with overrides replacing flags of the same name
+func merge(base, overrides Set) Set {
+	merged := make(Set, len(base)+len(overrides))
+	for name, f := range base {
+		merged[name] = f
+	}
+	for name, f := range overrides {
+		merged[name] = f
+	}
+	return merged
+}
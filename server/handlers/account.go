@@ -1,32 +1,51 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"fif/flags"
 	"fif/middleware"
+	"fif/problem"
 	"net/http"
 
 	"firebase.google.com/go/v4/auth"
 )
 
-// AccountHandler handles the /account endpoint
-func AccountHandler(w http.ResponseWriter, r *http.Request) {
-	token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
-	if !ok || token == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
+// FlagEvaluator evaluates feature flags for a user
+type FlagEvaluator interface {
+	Evaluate(ctx context.Context, userID string) map[string]bool
+}
+
+// AccountDTO is the signed-in user's profile and the features on for them
+type AccountDTO struct {
+	Email    string          `json:"email"`
+	Name     string          `json:"name"`
+	Features map[string]bool `json:"features"`
+}
 
-	email, _ := token.Claims["email"].(string)
-	name, _ := token.Claims["name"].(string)
+// MakeAccountHandler creates a handler for the /account endpoint. The
+// features let the web app hide pages that are not yet on for the user; a nil
+// evaluator reports none.
+func MakeAccountHandler(evaluator FlagEvaluator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
 
-	resp := map[string]string{
-		"email": email,
-		"name":  name,
-	}
+		email, _ := token.Claims["email"].(string)
+		name, _ := token.Claims["name"].(string)
+
+		resp := AccountDTO{Email: email, Name: name, Features: map[string]bool{}}
+		if evaluator != nil {
+			resp.Features = evaluator.Evaluate(r.Context(), flags.UserID(r))
+		}
 
-	w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Type", "application/json")
 
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fif/middleware"
+	"fif/problem"
+	"fif/problem/problemtest"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -29,7 +31,7 @@ func TestAccountHandler_Success(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Assert status code
 	if w.Code != http.StatusOK {
@@ -43,7 +45,7 @@ func TestAccountHandler_Success(t *testing.T) {
 	}
 
 	// Parse and assert response body
-	var resp map[string]string
+	var resp map[string]any
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
@@ -63,18 +65,14 @@ func TestAccountHandler_MissingToken(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Assert status code
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
 
-	// Assert response body
-	expectedBody := "unauthorized\n"
-	if w.Body.String() != expectedBody {
-		t.Errorf("Expected body %q, got %q", expectedBody, w.Body.String())
-	}
+	problemtest.Assert(t, w, problem.CodeUnauthorized)
 }
 
 func TestAccountHandler_NilToken(t *testing.T) {
@@ -86,18 +84,14 @@ func TestAccountHandler_NilToken(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Assert status code
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
 
-	// Assert response body
-	expectedBody := "unauthorized\n"
-	if w.Body.String() != expectedBody {
-		t.Errorf("Expected body %q, got %q", expectedBody, w.Body.String())
-	}
+	problemtest.Assert(t, w, problem.CodeUnauthorized)
 }
 
 func TestAccountHandler_WrongTypeInContext(t *testing.T) {
@@ -109,7 +103,7 @@ func TestAccountHandler_WrongTypeInContext(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Assert status code
 	if w.Code != http.StatusUnauthorized {
@@ -133,14 +127,14 @@ func TestAccountHandler_MissingEmailClaim(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Should still succeed with empty email
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var resp map[string]string
+	var resp map[string]any
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
@@ -170,14 +164,14 @@ func TestAccountHandler_MissingNameClaim(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Should still succeed with empty name
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var resp map[string]string
+	var resp map[string]any
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
@@ -208,14 +202,14 @@ func TestAccountHandler_NonStringClaims(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	// Execute the handler
-	AccountHandler(w, req)
+	MakeAccountHandler(nil)(w, req)
 
 	// Should still succeed but with empty values (type assertion fails)
 	if w.Code != http.StatusOK {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
 	}
 
-	var resp map[string]string
+	var resp map[string]any
 	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
@@ -229,3 +223,29 @@ func TestAccountHandler_NonStringClaims(t *testing.T) {
 		t.Errorf("Expected empty name for non-string claim, got %s", resp["name"])
 	}
 }
+
+// staticFlags is a FlagEvaluator with fixed results
+type staticFlags map[string]bool
+
+func (f staticFlags) Evaluate(ctx context.Context, userID string) map[string]bool {
+	return f
+}
+
+func TestAccountHandler_Features(t *testing.T) {
+	// Create a request with the token in context
+	req := httptest.NewRequest(http.MethodGet, "/account", nil)
+	ctx := context.WithValue(req.Context(), middleware.CtxTokenKey{}, &auth.Token{UID: "u1"})
+	req = req.WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	MakeAccountHandler(staticFlags{"tax-page": true, "cv-method": false})(w, req)
+
+	// Assert the evaluated flags are in the response
+	var resp AccountDTO
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !resp.Features["tax-page"] || resp.Features["cv-method"] {
+		t.Errorf("Unexpected features %v", resp.Features)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fif/middleware"
+	"fif/portfolio"
+	"fif/problem"
+	"fif/users"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MakeAdminUsersHandler creates a handler that lists every known user: those
+// with saved settings and those who only have holdings
+func MakeAdminUsersHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := users.List(r.Context(), db)
+		if err != nil {
+			problem.Internal(w, r, "Error listing users", err)
+			return
+		}
+
+		writeJSON(w, list)
+	}
+}
+
+// MakeSetUserRolesHandler creates a handler that replaces the roles of the user
+// named in the URL with {"roles": [...]}
+func MakeSetUserRolesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Roles []string `json:"roles"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Roles == nil {
+			problem.Validation(w, r, problem.FieldError{Field: "roles", Message: "must be an array of role names"})
+			return
+		}
+
+		if errs := validateRoles(req.Roles); len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+
+		if err := users.SetRoles(r.Context(), db, chi.URLParam(r, "id"), req.Roles); err != nil {
+			problem.Internal(w, r, "Error saving roles", err)
+			return
+		}
+
+		writeJSON(w, map[string][]string{"roles": req.Roles})
+	}
+}
+
+// MakeImportFailuresHandler creates a handler that lists recent import
+// failures, newest first, optionally for a single ?user= and limited by
+// ?limit= (default 100)
+func MakeImportFailuresHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 100
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 1000 {
+				problem.Validation(w, r, problem.FieldError{Field: "limit", Message: "must be between 1 and 1000"})
+				return
+			}
+			limit = n
+		}
+
+		failures, err := portfolio.ListImportFailures(r.Context(), db, r.URL.Query().Get("user"), limit)
+		if err != nil {
+			problem.Internal(w, r, "Error listing import failures", err)
+			return
+		}
+
+		writeJSON(w, failures)
+	}
+}
+
+// validateRoles rejects roles that are not in middleware.KnownRoles
+func validateRoles(roles []string) []problem.FieldError {
+	known := map[string]bool{}
+	for _, role := range middleware.KnownRoles {
+		known[role] = true
+	}
+
+	var errs []problem.FieldError
+	for i, role := range roles {
+		if !known[role] {
+			errs = append(errs, problem.FieldError{
+				Field:   fmt.Sprintf("roles[%d]", i),
+				Message: fmt.Sprintf("unknown role %q", role),
+			})
+		}
+	}
+	return errs
+}
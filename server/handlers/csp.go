@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fif/problem"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// maxCSPReportBytes bounds a violation report body
+const maxCSPReportBytes = 64 << 10
+
+// cspViolation is what is logged from a report
+type cspViolation struct {
+	DocumentURL        string
+	EffectiveDirective string
+	BlockedURL         string
+	SourceFile         string
+	LineNumber         int
+	Disposition        string
+}
+
+// reportURIBody is sent by browsers following the report-uri directive
+type reportURIBody struct {
+	Report struct {
+		DocumentURI        string `json:"document-uri"`
+		ViolatedDirective  string `json:"violated-directive"`
+		EffectiveDirective string `json:"effective-directive"`
+		BlockedURI         string `json:"blocked-uri"`
+		SourceFile         string `json:"source-file"`
+		LineNumber         int    `json:"line-number"`
+		Disposition        string `json:"disposition"`
+	} `json:"csp-report"`
+}
+
+// reportingAPIReport is one entry of a Reporting API batch, sent by browsers
+// following the report-to directive
+type reportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		DocumentURL        string `json:"documentURL"`
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
+
+// CSPReportHandler logs Content Security Policy violations reported by
+// browsers in either the report-uri or the Reporting API format
+func CSPReportHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportBytes))
+	if err != nil {
+		problem.Write(w, r, http.StatusRequestEntityTooLarge, problem.CodeValidationFailed, "the report is too large")
+		return
+	}
+
+	violations, err := parseCSPReports(r.Header.Get("Content-Type"), body)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, problem.CodeValidationFailed, "the report is not valid JSON")
+		return
+	}
+
+	for _, v := range violations {
+		slog.Warn("CSP violation",
+			"document", v.DocumentURL,
+			"directive", v.EffectiveDirective,
+			"blocked", v.BlockedURL,
+			"source", v.SourceFile,
+			"line", v.LineNumber,
+			"disposition", v.Disposition,
+		)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseCSPReports reads a report-uri body or a Reporting API batch, ignoring
+// reports of other types
+func parseCSPReports(contentType string, body []byte) ([]cspViolation, error) {
+	if strings.HasPrefix(contentType, "application/reports+json") {
+		var reports []reportingAPIReport
+		if err := json.Unmarshal(body, &reports); err != nil {
+			return nil, err
+		}
+
+		violations := []cspViolation{}
+		for _, rep := range reports {
+			if rep.Type != "csp-violation" {
+				continue
+			}
+			violations = append(violations, cspViolation{
+				DocumentURL:        rep.Body.DocumentURL,
+				EffectiveDirective: rep.Body.EffectiveDirective,
+				BlockedURL:         rep.Body.BlockedURL,
+				SourceFile:         rep.Body.SourceFile,
+				LineNumber:         rep.Body.LineNumber,
+				Disposition:        rep.Body.Disposition,
+			})
+		}
+		return violations, nil
+	}
+
+	var rep reportURIBody
+	if err := json.Unmarshal(body, &rep); err != nil {
+		return nil, err
+	}
+	directive := rep.Report.EffectiveDirective
+	if directive == "" {
+		directive = rep.Report.ViolatedDirective
+	}
+	return []cspViolation{{
+		DocumentURL:        rep.Report.DocumentURI,
+		EffectiveDirective: directive,
+		BlockedURL:         rep.Report.BlockedURI,
+		SourceFile:         rep.Report.SourceFile,
+		LineNumber:         rep.Report.LineNumber,
+		Disposition:        rep.Report.Disposition,
+	}}, nil
+}
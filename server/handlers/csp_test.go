@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseCSPReports(t *testing.T) {
+	testCases := []struct {
+		name              string
+		contentType       string
+		body              string
+		expectedDirective []string
+	}{
+		{
+			name:              "ReportURI",
+			contentType:       "application/csp-report",
+			body:              `{"csp-report":{"document-uri":"https://fif.example.com/","violated-directive":"script-src-elem","blocked-uri":"inline"}}`,
+			expectedDirective: []string{"script-src-elem"},
+		},
+		{
+			name:        "ReportingAPI",
+			contentType: "application/reports+json",
+			body: `[{"type":"csp-violation","body":{"documentURL":"https://fif.example.com/","effectiveDirective":"connect-src","blockedURL":"https://evil.example"}},` +
+				`{"type":"deprecation","body":{}}]`,
+			expectedDirective: []string{"connect-src"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := parseCSPReports(tc.contentType, []byte(tc.body))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Assert only CSP violations are read, in either format
+			if len(violations) != len(tc.expectedDirective) {
+				t.Fatalf("Expected %d violations, got %d", len(tc.expectedDirective), len(violations))
+			}
+			for i, v := range violations {
+				if v.EffectiveDirective != tc.expectedDirective[i] || v.DocumentURL != "https://fif.example.com/" {
+					t.Errorf("Unexpected violation %+v", v)
+				}
+			}
+		})
+	}
+}
+
+func TestCSPReportHandler_RejectsBadJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/csp-report", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/csp-report")
+	w := httptest.NewRecorder()
+
+	CSPReportHandler(w, req)
+
+	// Assert a malformed report is a 400
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fif/flags"
+	"fif/problem"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MakeListFlagsHandler creates a handler that lists every feature flag and
+// whether it comes from the flags file or a database override
+func MakeListFlagsHandler(store *flags.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defs, err := store.List(r.Context())
+		if err != nil {
+			problem.Internal(w, r, "Error listing feature flags", err)
+			return
+		}
+		writeJSON(w, defs)
+	}
+}
+
+// MakePutFlagHandler creates a handler that overrides the flag named in the
+// URL; the override takes effect on every replica within the refresh interval
+func MakePutFlagHandler(store *flags.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var f flags.Flag
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "body", Message: "must be a JSON flag"})
+			return
+		}
+
+		name := chi.URLParam(r, "name")
+		var errs []problem.FieldError
+		if !flags.ValidName(name) {
+			errs = append(errs, problem.FieldError{Field: "name", Message: "must be lowercase letters, digits and dashes"})
+		}
+		if err := f.Validate(); err != nil {
+			errs = append(errs, problem.FieldError{Field: "percentage", Message: "must be between 0 and 100"})
+		}
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+
+		if err := store.SetOverride(r.Context(), name, f); err != nil {
+			problem.Internal(w, r, "Error saving feature flag", err)
+			return
+		}
+		writeJSON(w, flags.Definition{Name: name, Source: flags.SourceDatabase, Flag: f})
+	}
+}
+
+// MakeDeleteFlagHandler creates a handler that removes the override of the flag
+// named in the URL, so the flags file applies again
+func MakeDeleteFlagHandler(store *flags.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		deleted, err := store.DeleteOverride(r.Context(), chi.URLParam(r, "name"))
+		if err != nil {
+			problem.Internal(w, r, "Error deleting feature flag", err)
+			return
+		}
+		if !deleted {
+			problem.NotFound(w, r, "feature flag override not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
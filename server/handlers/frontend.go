@@ -0,0 +1,43 @@
+package handlers
+
+import "net/http"
+
+// FrontendConfig is the runtime configuration of the web app. Everything in it
+// is public: it is served to anyone who loads the page.
+type FrontendConfig struct {
+	// APIURL is the base URL of the API, such as /api
+	APIURL string `json:"apiUrl"`
+	// Environment is APP_ENV, or "production" when it is not set
+	Environment string       `json:"environment"`
+	Auth        FrontendAuth `json:"auth"`
+	// Features are the flags on for anonymous visitors, which means on for
+	// everyone; they are filled in on each request
+	Features map[string]bool `json:"features"`
+	// Demo is true when visitors without an account see the demo portfolio
+	Demo bool `json:"demo"`
+}
+
+// FrontendAuth tells the web app how to sign users in
+type FrontendAuth struct {
+	Provider        string `json:"provider"`
+	SupabaseURL     string `json:"supabaseUrl,omitempty"`
+	SupabaseAnonKey string `json:"supabaseAnonKey,omitempty"`
+	RedirectURL     string `json:"redirectUrl,omitempty"`
+}
+
+// MakeFrontendConfigHandler serves cfg as /config.json, which the web app
+// loads before it starts, so one build runs in every environment. Features
+// come from evaluator for an anonymous visitor; a nil evaluator reports none.
+func MakeFrontendConfigHandler(cfg FrontendConfig, evaluator FlagEvaluator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := cfg
+		resp.Features = map[string]bool{}
+		if evaluator != nil {
+			resp.Features = evaluator.Evaluate(r.Context(), "")
+		}
+
+		// Changes with the server's configuration, not with the build
+		w.Header().Set("Cache-Control", cacheRevalidate)
+		writeJSON(w, resp)
+	}
+}
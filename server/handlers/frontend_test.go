@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFrontendConfigHandler(t *testing.T) {
+	handler := MakeFrontendConfigHandler(FrontendConfig{
+		APIURL:      "/api",
+		Environment: "staging",
+		Auth:        FrontendAuth{Provider: "supabase", SupabaseURL: "https://staging.supabase.co"},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/config.json", nil))
+
+	// Assert the settings are served as JSON and revalidated
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != cacheRevalidate {
+		t.Errorf("Expected Cache-Control %q, got %q", cacheRevalidate, got)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body["apiUrl"] != "/api" || body["environment"] != "staging" {
+		t.Errorf("Unexpected config %v", body)
+	}
+	if features, ok := body["features"].(map[string]any); !ok || len(features) != 0 {
+		t.Errorf("Expected empty features object, got %v", body["features"])
+	}
+	auth, _ := body["auth"].(map[string]any)
+	if auth["supabaseUrl"] != "https://staging.supabase.co" {
+		t.Errorf("Expected Supabase URL, got %v", auth)
+	}
+}
+
+func TestFrontendConfigHandler_AnonymousFeatures(t *testing.T) {
+	// Create an evaluator that records who it was asked about
+	var asked []string
+	evaluator := recordingFlags(func(userID string) map[string]bool {
+		asked = append(asked, userID)
+		return map[string]bool{"tax-page": true}
+	})
+
+	w := httptest.NewRecorder()
+	MakeFrontendConfigHandler(FrontendConfig{}, evaluator)(w, httptest.NewRequest(http.MethodGet, "/config.json", nil))
+
+	// Assert the features are those on for an anonymous visitor
+	var body FrontendConfig
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !body.Features["tax-page"] {
+		t.Errorf("Expected tax-page on, got %v", body.Features)
+	}
+	if len(asked) != 1 || asked[0] != "" {
+		t.Errorf("Expected one anonymous evaluation, got %q", asked)
+	}
+}
+
+// recordingFlags is a FlagEvaluator backed by a function
+type recordingFlags func(userID string) map[string]bool
+
+func (f recordingFlags) Evaluate(ctx context.Context, userID string) map[string]bool {
+	return f(userID)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fif/middleware"
+	"fif/portfolio"
+	"fif/problem"
+	"fif/tax"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBackfillDays limits how many days a single backfill request may cover
+const maxBackfillDays = 366 * 5
+
+// HistoryDTO is a portfolio time series for charting
+type HistoryDTO struct {
+	From     string                   `json:"from"`
+	To       string                   `json:"to"`
+	Interval string                   `json:"interval"`
+	Points   []portfolio.HistoryPoint `json:"points"`
+}
+
+// MakeHistoryHandler creates a handler that returns daily snapshots between
+// ?from= and ?to= (default the last year), resampled by ?interval=day|week|month
+func MakeHistoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		from, to, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		interval := r.URL.Query().Get("interval")
+		switch interval {
+		case "":
+			interval = portfolio.IntervalDay
+		case portfolio.IntervalDay, portfolio.IntervalWeek, portfolio.IntervalMonth:
+		default:
+			problem.Validation(w, r, problem.FieldError{Field: "interval", Message: "must be day, week or month"})
+			return
+		}
+
+		points, err := portfolio.LoadHistory(r.Context(), db, token.UID, from, to)
+		if err != nil {
+			problem.Internal(w, r, "Error loading portfolio history", err)
+			return
+		}
+
+		points, err = portfolio.Resample(points, interval)
+		if err != nil {
+			problem.Internal(w, r, "Error resampling portfolio history", err)
+			return
+		}
+
+		resp := HistoryDTO{
+			From:     from.Format(portfolio.DateLayout),
+			To:       to.Format(portfolio.DateLayout),
+			Interval: interval,
+			Points:   points,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// BackfillQueue queues history backfills to run in the background
+type BackfillQueue interface {
+	Enqueue(ctx context.Context, userID string, from, to time.Time) (portfolio.BackfillRequest, error)
+	Get(ctx context.Context, userID string, id int64) (portfolio.BackfillRequest, error)
+}
+
+// JobTrigger starts a scheduled job now
+type JobTrigger interface {
+	Trigger(ctx context.Context, name string) (bool, error)
+}
+
+// MakeBackfillHandler creates a handler that queues snapshots for every day
+// between ?from= and ?to= from stored prices and rates. It answers 202 with
+// the queued backfill and starts the backfill job rather than waiting for it.
+func MakeBackfillHandler(queue BackfillQueue, trigger JobTrigger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		from, to, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		if to.Sub(from) > maxBackfillDays*24*time.Hour {
+			problem.Validation(w, r, problem.FieldError{Field: "from", Message: "date range must not exceed five years"})
+			return
+		}
+
+		backfill, err := queue.Enqueue(r.Context(), token.UID, from, to)
+		if err != nil {
+			problem.Internal(w, r, "Error queueing portfolio backfill", err)
+			return
+		}
+
+		// The job also runs on a schedule, so a backfill that cannot start
+		// now is picked up by the next run
+		if _, err := trigger.Trigger(r.Context(), portfolio.BackfillJob); err != nil {
+			slog.WarnContext(r.Context(), "Error starting backfill job", "error", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", fmt.Sprintf("/api/portfolio/history/backfill/%d", backfill.ID))
+		w.WriteHeader(http.StatusAccepted)
+		if err := json.NewEncoder(w).Encode(backfill); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// MakeGetBackfillHandler creates a handler that returns the progress of the
+// backfill whose id is in the URL
+func MakeGetBackfillHandler(queue BackfillQueue) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil {
+			problem.NotFound(w, r, "backfill not found")
+			return
+		}
+
+		backfill, err := queue.Get(r.Context(), token.UID, id)
+		if errors.Is(err, portfolio.ErrBackfillNotFound) {
+			problem.NotFound(w, r, "backfill not found")
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, "Error loading portfolio backfill", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(backfill); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// parseDateRange reads ?from= and ?to=, defaulting to the year ending today in
+// New Zealand. It writes a validation problem and returns false when the range
+// is invalid.
+func parseDateRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	today := tax.Date(time.Now())
+
+	to, err := parseDateParam(r, "to", today)
+	if err != nil {
+		problem.Validation(w, r, problem.FieldError{Field: "to", Message: dateFormatMessage})
+		return time.Time{}, time.Time{}, false
+	}
+
+	from, err := parseDateParam(r, "from", to.AddDate(-1, 0, 0))
+	if err != nil {
+		problem.Validation(w, r, problem.FieldError{Field: "from", Message: dateFormatMessage})
+		return time.Time{}, time.Time{}, false
+	}
+
+	if from.After(to) {
+		problem.Validation(w, r, problem.FieldError{Field: "from", Message: "must not be after to"})
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fif/middleware"
+	"fif/portfolio"
+	"fif/problem"
+	"fif/problem/problemtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// mockBackfillQueue is a mock implementation of the BackfillQueue interface for testing
+type mockBackfillQueue struct {
+	queued []portfolio.BackfillRequest
+}
+
+func (m *mockBackfillQueue) Enqueue(ctx context.Context, userID string, from, to time.Time) (portfolio.BackfillRequest, error) {
+	b := portfolio.BackfillRequest{
+		ID:     int64(len(m.queued) + 1),
+		From:   from.Format(portfolio.DateLayout),
+		To:     to.Format(portfolio.DateLayout),
+		Status: portfolio.BackfillPending,
+	}
+	m.queued = append(m.queued, b)
+	return b, nil
+}
+
+func (m *mockBackfillQueue) Get(ctx context.Context, userID string, id int64) (portfolio.BackfillRequest, error) {
+	if userID != "u1" || id < 1 || id > int64(len(m.queued)) {
+		return portfolio.BackfillRequest{}, portfolio.ErrBackfillNotFound
+	}
+	return m.queued[id-1], nil
+}
+
+// mockJobTrigger records the jobs it was asked to start
+type mockJobTrigger struct {
+	triggered []string
+}
+
+func (m *mockJobTrigger) Trigger(ctx context.Context, name string) (bool, error) {
+	m.triggered = append(m.triggered, name)
+	return true, nil
+}
+
+func withUser(r *http.Request, uid string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), middleware.CtxTokenKey{}, &auth.Token{UID: uid}))
+}
+
+func TestBackfillHandler_QueuesAndStartsJob(t *testing.T) {
+	// Create a request for a long range
+	queue := &mockBackfillQueue{}
+	trigger := &mockJobTrigger{}
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/portfolio/history/backfill?from=2021-01-01&to=2025-12-31", nil), "u1")
+	w := httptest.NewRecorder()
+
+	MakeBackfillHandler(queue, trigger).ServeHTTP(w, req)
+
+	// Assert the backfill is queued and accepted without running it here
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/api/portfolio/history/backfill/1" {
+		t.Errorf("Expected Location of the backfill, got %q", loc)
+	}
+	var resp portfolio.BackfillRequest
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.From != "2021-01-01" || resp.To != "2025-12-31" || resp.Status != portfolio.BackfillPending {
+		t.Errorf("Expected the pending backfill, got %+v", resp)
+	}
+	if len(trigger.triggered) != 1 || trigger.triggered[0] != portfolio.BackfillJob {
+		t.Errorf("Expected the backfill job to be started, got %v", trigger.triggered)
+	}
+}
+
+func TestBackfillHandler_RejectsLongRange(t *testing.T) {
+	queue := &mockBackfillQueue{}
+	req := withUser(httptest.NewRequest(http.MethodPost, "/api/portfolio/history/backfill?from=2015-01-01&to=2025-12-31", nil), "u1")
+	w := httptest.NewRecorder()
+
+	MakeBackfillHandler(queue, &mockJobTrigger{}).ServeHTTP(w, req)
+
+	// Assert nothing is queued
+	problemtest.Assert(t, w, problem.CodeValidationFailed)
+	if len(queue.queued) != 0 {
+		t.Errorf("Expected nothing queued, got %v", queue.queued)
+	}
+}
+
+func TestGetBackfillHandler(t *testing.T) {
+	queue := &mockBackfillQueue{}
+	if _, err := queue.Enqueue(context.Background(), "u1", time.Now(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(uid, id string) *httptest.ResponseRecorder {
+		r := chi.NewRouter()
+		r.Get("/api/portfolio/history/backfill/{id}", MakeGetBackfillHandler(queue))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, withUser(httptest.NewRequest(http.MethodGet, "/api/portfolio/history/backfill/"+id, nil), uid))
+		return w
+	}
+
+	// Assert the owner sees the backfill and nobody else does
+	if w := get("u1", "1"); w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	problemtest.Assert(t, get("u2", "1"), problem.CodeNotFound)
+	problemtest.Assert(t, get("u1", "x"), problem.CodeNotFound)
+}
+
+func TestHistoryHandler_RejectsIntervalBeforeQuerying(t *testing.T) {
+	req := withUser(httptest.NewRequest(http.MethodGet, "/api/portfolio/history?interval=year", nil), "u1")
+	w := httptest.NewRecorder()
+
+	// Assert a bad interval is refused without the database, which is nil here
+	MakeHistoryHandler(nil).ServeHTTP(w, req)
+
+	problemtest.Assert(t, w, problem.CodeValidationFailed)
+}
@@ -4,7 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fif/middleware"
-	"log"
+	"fif/problem"
 	"net/http"
 
 	"firebase.google.com/go/v4/auth"
@@ -25,22 +25,21 @@ func MakeHoldingsHandler(db *sql.DB) http.HandlerFunc {
 		// Extract the auth token from context
 		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
 		if !ok || token == nil {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			problem.Unauthorized(w, r)
 			return
 		}
 
 		userID := token.UID
 
 		// Query holdings for this user
-		rows, err := db.Query(`
+		rows, err := db.QueryContext(r.Context(), `
 			SELECT name, symbol, quantity, currency, cost
 			FROM holdings
 			WHERE user_id = $1
 			ORDER BY created_at DESC
 		`, userID)
 		if err != nil {
-			log.Printf("Error querying holdings: %v", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+			problem.Internal(w, r, "Error querying holdings", err)
 			return
 		}
 		defer rows.Close()
@@ -49,16 +48,14 @@ func MakeHoldingsHandler(db *sql.DB) http.HandlerFunc {
 		for rows.Next() {
 			var h HoldingDTO
 			if err := rows.Scan(&h.Name, &h.Symbol, &h.Quantity, &h.Currency, &h.Cost); err != nil {
-				log.Printf("Error scanning holding: %v", err)
-				http.Error(w, "internal server error", http.StatusInternalServerError)
+				problem.Internal(w, r, "Error scanning holding", err)
 				return
 			}
 			holdings = append(holdings, h)
 		}
 
 		if err := rows.Err(); err != nil {
-			log.Printf("Error iterating holdings: %v", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+			problem.Internal(w, r, "Error iterating holdings", err)
 			return
 		}
 
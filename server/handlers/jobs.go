@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fif/jobs"
+	"fif/problem"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// MakeJobsHandler creates a handler that lists scheduled jobs and their last run
+func MakeJobsHandler(scheduler *jobs.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses, err := scheduler.Status(r.Context())
+		if err != nil {
+			problem.Internal(w, r, "Error listing jobs", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(statuses); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// MakeJobRunsHandler creates a handler that returns recent runs of the job named
+// in the URL, limited by ?limit= (default 20)
+func MakeJobRunsHandler(scheduler *jobs.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 500 {
+				problem.Validation(w, r, problem.FieldError{Field: "limit", Message: "must be between 1 and 500"})
+				return
+			}
+			limit = n
+		}
+
+		runs, err := scheduler.Runs(r.Context(), chi.URLParam(r, "name"), limit)
+		if errors.Is(err, jobs.ErrUnknownJob) {
+			problem.NotFound(w, r, "job not found")
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, "Error listing job runs", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(runs); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// MakeRunJobHandler creates a handler that starts the job named in the URL
+func MakeRunJobHandler(scheduler *jobs.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+
+		started, err := scheduler.Trigger(r.Context(), name)
+		if errors.Is(err, jobs.ErrUnknownJob) {
+			problem.NotFound(w, r, "job not found")
+			return
+		}
+		if errors.Is(err, jobs.ErrStopped) {
+			problem.Write(w, r, http.StatusServiceUnavailable, problem.CodeUnavailable, "server is shutting down")
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, "Error triggering job "+name, err)
+			return
+		}
+		if !started {
+			problem.Conflict(w, r, "job is already running")
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
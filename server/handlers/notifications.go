@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fif/middleware"
+	"fif/problem"
+	"net/http"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// NotificationSettingsDTO is a user's email notification opt-in
+type NotificationSettingsDTO struct {
+	Enabled bool   `json:"enabled"`
+	Email   string `json:"email"`
+}
+
+// MakeGetNotificationSettingsHandler creates a handler that returns the user's
+// notification settings, defaulting to disabled
+func MakeGetNotificationSettingsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		settings := NotificationSettingsDTO{}
+		settings.Email, _ = token.Claims["email"].(string)
+
+		err := db.QueryRowContext(r.Context(), `
+			SELECT notifications_enabled FROM users WHERE id = $1
+		`, token.UID).Scan(&settings.Enabled)
+		if err != nil && err != sql.ErrNoRows {
+			problem.Internal(w, r, "Error querying notification settings", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// MakePutNotificationSettingsHandler creates a handler that opts the user in or
+// out of email notifications, sent to the email address on their token
+func MakePutNotificationSettingsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			problem.Validation(w, r, problem.FieldError{Field: "enabled", Message: "must be true or false"})
+			return
+		}
+
+		email, _ := token.Claims["email"].(string)
+		if *req.Enabled && email == "" {
+			problem.Validation(w, r, problem.FieldError{Field: "email", Message: "account has no email address"})
+			return
+		}
+
+		if _, err := db.ExecContext(r.Context(), `
+			INSERT INTO users (id, email, notifications_enabled)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (id) DO UPDATE
+				SET email = EXCLUDED.email, notifications_enabled = EXCLUDED.notifications_enabled
+		`, token.UID, email, *req.Enabled); err != nil {
+			problem.Internal(w, r, "Error saving notification settings", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(NotificationSettingsDTO{Enabled: *req.Enabled, Email: email}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
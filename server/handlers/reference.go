@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fif/portfolio"
+	"fif/problem"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// currencyPattern matches ISO 4217 currency codes, as the schema requires
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// maxSymbolLength matches the width of the symbol columns
+const maxSymbolLength = 16
+
+// InstrumentDTO is reference data for a tradeable instrument
+type InstrumentDTO struct {
+	Symbol   string `json:"symbol"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	Exchange string `json:"exchange"`
+}
+
+// FXRateDTO is the number of units of currency per 1 NZD on a date
+type FXRateDTO struct {
+	Currency string  `json:"currency"`
+	Date     string  `json:"date"`
+	Rate     float64 `json:"rate"`
+}
+
+// PriceDTO is a closing price for a symbol on a date
+type PriceDTO struct {
+	Symbol string  `json:"symbol"`
+	Date   string  `json:"date"`
+	Close  float64 `json:"close"`
+}
+
+// MakeListInstrumentsHandler creates a handler that lists all instruments
+func MakeListInstrumentsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT symbol, name, currency, exchange
+			FROM instruments
+			ORDER BY symbol
+		`)
+		if err != nil {
+			problem.Internal(w, r, "Error querying instruments", err)
+			return
+		}
+		defer rows.Close()
+
+		instruments := []InstrumentDTO{}
+		for rows.Next() {
+			var i InstrumentDTO
+			if err := rows.Scan(&i.Symbol, &i.Name, &i.Currency, &i.Exchange); err != nil {
+				problem.Internal(w, r, "Error scanning instrument", err)
+				return
+			}
+			instruments = append(instruments, i)
+		}
+
+		if err := rows.Err(); err != nil {
+			problem.Internal(w, r, "Error iterating instruments", err)
+			return
+		}
+
+		writeJSON(w, instruments)
+	}
+}
+
+// MakePutInstrumentHandler creates a handler that creates or replaces the
+// instrument whose symbol is in the URL
+func MakePutInstrumentHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var i InstrumentDTO
+		if err := json.NewDecoder(r.Body).Decode(&i); err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "body", Message: "must be a JSON instrument"})
+			return
+		}
+		i.Symbol = chi.URLParam(r, "symbol")
+
+		var errs []problem.FieldError
+		errs = append(errs, validateSymbol("symbol", i.Symbol)...)
+		if i.Name == "" {
+			errs = append(errs, problem.FieldError{Field: "name", Message: "is required"})
+		}
+		if !currencyPattern.MatchString(i.Currency) {
+			errs = append(errs, problem.FieldError{Field: "currency", Message: "must be a three letter currency code"})
+		}
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+
+		if _, err := db.ExecContext(r.Context(), `
+			INSERT INTO instruments (symbol, name, currency, exchange)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (symbol) DO UPDATE
+				SET name = EXCLUDED.name, currency = EXCLUDED.currency, exchange = EXCLUDED.exchange
+		`, i.Symbol, i.Name, i.Currency, i.Exchange); err != nil {
+			problem.Internal(w, r, "Error saving instrument", err)
+			return
+		}
+
+		writeJSON(w, i)
+	}
+}
+
+// MakeDeleteInstrumentHandler creates a handler that removes the instrument
+// whose symbol is in the URL
+func MakeDeleteInstrumentHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := db.ExecContext(r.Context(), `DELETE FROM instruments WHERE symbol = $1`, chi.URLParam(r, "symbol"))
+		if err != nil {
+			problem.Internal(w, r, "Error deleting instrument", err)
+			return
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			problem.NotFound(w, r, "instrument not found")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MakeListFXRatesHandler creates a handler that lists FX rates between ?from=
+// and ?to=, optionally for a single ?currency=
+func MakeListFXRatesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		from, to, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		rows, err := db.QueryContext(r.Context(), `
+			SELECT currency, rate_date, rate
+			FROM fx_rates
+			WHERE rate_date BETWEEN $1 AND $2 AND ($3 = '' OR currency = $3)
+			ORDER BY currency, rate_date
+		`, from.Format(portfolio.DateLayout), to.Format(portfolio.DateLayout), r.URL.Query().Get("currency"))
+		if err != nil {
+			problem.Internal(w, r, "Error querying fx rates", err)
+			return
+		}
+		defer rows.Close()
+
+		rates := []FXRateDTO{}
+		for rows.Next() {
+			var rate FXRateDTO
+			var date time.Time
+			if err := rows.Scan(&rate.Currency, &date, &rate.Rate); err != nil {
+				problem.Internal(w, r, "Error scanning fx rate", err)
+				return
+			}
+			rate.Date = date.Format(portfolio.DateLayout)
+			rates = append(rates, rate)
+		}
+
+		if err := rows.Err(); err != nil {
+			problem.Internal(w, r, "Error iterating fx rates", err)
+			return
+		}
+
+		writeJSON(w, rates)
+	}
+}
+
+// MakePutFXRatesHandler creates a handler that upserts a JSON array of FX rates
+func MakePutFXRatesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var rates []FXRateDTO
+		if err := json.NewDecoder(r.Body).Decode(&rates); err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "body", Message: "must be a JSON array of rates"})
+			return
+		}
+
+		var errs []problem.FieldError
+		for i, rate := range rates {
+			if !currencyPattern.MatchString(rate.Currency) {
+				errs = append(errs, problem.FieldError{Field: fmt.Sprintf("[%d].currency", i), Message: "must be a three letter currency code"})
+			}
+			if _, err := time.Parse(portfolio.DateLayout, rate.Date); err != nil {
+				errs = append(errs, problem.FieldError{Field: fmt.Sprintf("[%d].date", i), Message: dateFormatMessage})
+			}
+			if rate.Rate <= 0 {
+				errs = append(errs, problem.FieldError{Field: fmt.Sprintf("[%d].rate", i), Message: "must be greater than zero"})
+			}
+		}
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+
+		err := upsertAll(r, db, len(rates), `
+			INSERT INTO fx_rates (currency, rate_date, rate)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (currency, rate_date) DO UPDATE SET rate = EXCLUDED.rate
+		`, func(i int) []any { return []any{rates[i].Currency, rates[i].Date, rates[i].Rate} })
+		if err != nil {
+			problem.Internal(w, r, "Error saving fx rates", err)
+			return
+		}
+
+		writeJSON(w, map[string]int{"saved": len(rates)})
+	}
+}
+
+// MakePutPricesHandler creates a handler that upserts a JSON array of closing prices
+func MakePutPricesHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var prices []PriceDTO
+		if err := json.NewDecoder(r.Body).Decode(&prices); err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "body", Message: "must be a JSON array of prices"})
+			return
+		}
+
+		var errs []problem.FieldError
+		for i, price := range prices {
+			errs = append(errs, validateSymbol(fmt.Sprintf("[%d].symbol", i), price.Symbol)...)
+			if _, err := time.Parse(portfolio.DateLayout, price.Date); err != nil {
+				errs = append(errs, problem.FieldError{Field: fmt.Sprintf("[%d].date", i), Message: dateFormatMessage})
+			}
+			if price.Close < 0 {
+				errs = append(errs, problem.FieldError{Field: fmt.Sprintf("[%d].close", i), Message: "must not be negative"})
+			}
+		}
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+
+		err := upsertAll(r, db, len(prices), `
+			INSERT INTO prices (symbol, price_date, close)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (symbol, price_date) DO UPDATE SET close = EXCLUDED.close
+		`, func(i int) []any { return []any{prices[i].Symbol, prices[i].Date, prices[i].Close} })
+		if err != nil {
+			problem.Internal(w, r, "Error saving prices", err)
+			return
+		}
+
+		writeJSON(w, map[string]int{"saved": len(prices)})
+	}
+}
+
+// upsertAll runs query once per row in a single transaction
+func upsertAll(r *http.Request, db *sql.DB, n int, query string, args func(i int) []any) error {
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(r.Context(), query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := 0; i < n; i++ {
+		if _, err := stmt.ExecContext(r.Context(), args(i)...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func validateSymbol(field, symbol string) []problem.FieldError {
+	if symbol == "" || len(symbol) > maxSymbolLength {
+		return []problem.FieldError{{Field: field, Message: fmt.Sprintf("must be 1 to %d characters", maxSymbolLength)}}
+	}
+	return nil
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fif/middleware"
+	"fif/portfolio"
+	"fif/problem"
+	"net/http"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// MakeReturnsHandler creates a handler that computes time-weighted and money-weighted
+// returns between ?from= and ?to=, optionally against a ?benchmark= symbol
+func MakeReturnsHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		from, to, ok := parseDateRange(w, r)
+		if !ok {
+			return
+		}
+
+		rows, err := portfolio.LoadSnapshotRows(r.Context(), db, token.UID, from, to)
+		if err != nil {
+			problem.Internal(w, r, "Error loading snapshots for returns", err)
+			return
+		}
+
+		report := portfolio.ComputeReturns(rows)
+		report.From = from.Format(portfolio.DateLayout)
+		report.To = to.Format(portfolio.DateLayout)
+
+		if symbol := r.URL.Query().Get("benchmark"); symbol != "" {
+			benchmark, err := portfolio.LoadBenchmark(r.Context(), db, symbol, from, to)
+			if err != nil {
+				problem.Internal(w, r, "Error loading benchmark", err)
+				return
+			}
+			report.Benchmark = &benchmark
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
@@ -1,42 +1,190 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fif/middleware"
 	"io"
 	"io/fs"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+// Cache-Control values. Vite puts a content hash in the name of everything
+// under assets/, so those never change; anything else may change on deploy.
+// A page carrying a nonce must never be reused.
+const (
+	cacheImmutable  = "public, max-age=31536000, immutable"
+	cacheRevalidate = "no-cache"
+	cacheNever      = "no-store"
+)
+
+// NoncePlaceholder is what Vite's html.cspNonce option writes into index.html;
+// it is replaced with the request's CSP nonce
+const NoncePlaceholder = "__CSP_NONCE__"
+
+// assetDir holds Vite's hashed build output
+const assetDir = "assets/"
+
+// assetExtensions are file types that are never SPA routes, so a missing one
+// is a 404 rather than index.html
+var assetExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".map": true, ".json": true, ".wasm": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true, ".avif": true, ".ico": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".txt": true, ".webmanifest": true,
+}
+
+// encodings are the precompressed variants looked for next to each file, in
+// order of preference
+var encodings = []struct{ name, ext string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
 // SPAHandler returns an http.HandlerFunc that serves static files from the provided
 // filesystem and falls back to index.html for any routes that aren't found.
+// Hashed assets are cached for good and everything else is revalidated by
+// ETag. A .br or .gz copy of a file is served instead when the client accepts
+// it. Missing assets are a 404 so a stale page fails clearly rather than
+// receiving index.html as JavaScript. When the request has a CSP nonce it is
+// written into index.html in place of NoncePlaceholder.
 func SPAHandler(staticFS fs.FS) http.HandlerFunc {
-	fileServer := http.FileServer(http.FS(staticFS))
+	etags := &etagCache{fs: staticFS}
+	index := sync.OnceValue(func() []byte {
+		data, _ := fs.ReadFile(staticFS, "index.html")
+		return data
+	})
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
 
-		// If the file exists in the FS, serve it
-		f, err := staticFS.Open(strings.TrimPrefix(path, "/"))
-		if err == nil {
-			f.Close()
-			fileServer.ServeHTTP(w, r)
-			return
+		if !isFile(staticFS, name) {
+			if isAsset(name) {
+				w.Header().Set("Cache-Control", cacheRevalidate)
+				http.NotFound(w, r)
+				return
+			}
+			// Fallback to index.html for SPA routes
+			name = "index.html"
 		}
 
-		// Fallback to index.html for SPA routes
-		index, err := staticFS.Open("index.html")
-		if err != nil {
-			http.NotFound(w, r)
+		if nonce := middleware.CSPNonce(r.Context()); name == "index.html" && nonce != "" &&
+			bytes.Contains(index(), []byte(NoncePlaceholder)) {
+			w.Header().Set("Cache-Control", cacheNever)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(bytes.ReplaceAll(index(), []byte(NoncePlaceholder), []byte(nonce)))
 			return
 		}
-		defer index.Close()
 
-		stat, err := index.Stat()
-		if err != nil {
-			http.NotFound(w, r)
-			return
+		if strings.HasPrefix(name, assetDir) {
+			w.Header().Set("Cache-Control", cacheImmutable)
+		} else {
+			w.Header().Set("Cache-Control", cacheRevalidate)
+		}
+
+		serveFile(w, r, staticFS, etags, name)
+	}
+}
+
+// serveFile writes name, or its best precompressed variant the client accepts
+func serveFile(w http.ResponseWriter, r *http.Request, staticFS fs.FS, etags *etagCache, name string) {
+	served := name
+	for _, enc := range encodings {
+		if !isFile(staticFS, name+enc.ext) {
+			continue
+		}
+		// Caches must key on Accept-Encoding once any variant exists
+		w.Header().Set("Vary", "Accept-Encoding")
+		if served == name && acceptsEncoding(r, enc.name) {
+			served = name + enc.ext
+			w.Header().Set("Content-Encoding", enc.name)
+		}
+	}
+
+	f, err := staticFS.Open(served)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if etag, err := etags.get(served); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+
+	// The original name picks the Content-Type; embedded files have no
+	// modification time, so the ETag does the revalidation
+	http.ServeContent(w, r, name, time.Time{}, content)
+}
+
+// isFile reports whether name is a regular file in fsys
+func isFile(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// isAsset reports whether name is a static file rather than a client route
+func isAsset(name string) bool {
+	return strings.HasPrefix(name+"/", assetDir) || assetExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header allows coding
+func acceptsEncoding(r *http.Request, coding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		value, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(value), coding) {
+			continue
 		}
+		// q=0 means the coding is not acceptable
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+		return true
+	}
+	return false
+}
+
+// etagCache remembers a strong ETag, the hash of its content, for each file.
+// The filesystem is embedded, so a file never changes once hashed.
+type etagCache struct {
+	fs    fs.FS
+	mu    sync.Mutex
+	etags map[string]string
+}
+
+func (c *etagCache) get(name string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if etag, ok := c.etags[name]; ok {
+		return etag, nil
+	}
+
+	data, err := fs.ReadFile(c.fs, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
 
-		http.ServeContent(w, r, "index.html", stat.ModTime(), index.(io.ReadSeeker))
+	if c.etags == nil {
+		c.etags = map[string]string{}
 	}
+	c.etags[name] = etag
+	return etag, nil
 }
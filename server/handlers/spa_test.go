@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"fif/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// testDist is a Vite build with a precompressed bundle
+var testDist = fstest.MapFS{
+	"index.html":                {Data: []byte("<!doctype html><div id=root></div>")},
+	"favicon.svg":               {Data: []byte("<svg/>")},
+	"assets/index-abc123.js":    {Data: []byte("console.log('app')")},
+	"assets/index-abc123.js.br": {Data: []byte("brotli")},
+	"assets/index-abc123.js.gz": {Data: []byte("gzip")},
+}
+
+func TestSPAHandler(t *testing.T) {
+	testCases := []struct {
+		name             string
+		path             string
+		acceptEncoding   string
+		expectedStatus   int
+		expectedBody     string
+		expectedCache    string
+		expectedEncoding string
+		expectedType     string
+	}{
+		{
+			name:           "Index",
+			path:           "/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<!doctype html><div id=root></div>",
+			expectedCache:  cacheRevalidate,
+			expectedType:   "text/html; charset=utf-8",
+		},
+		{
+			name:           "ClientRoute",
+			path:           "/portfolio/returns",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<!doctype html><div id=root></div>",
+			expectedCache:  cacheRevalidate,
+			expectedType:   "text/html; charset=utf-8",
+		},
+		{
+			name:           "HashedAsset",
+			path:           "/assets/index-abc123.js",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "console.log('app')",
+			expectedCache:  cacheImmutable,
+			expectedType:   "text/javascript; charset=utf-8",
+		},
+		{
+			name:             "Brotli",
+			path:             "/assets/index-abc123.js",
+			acceptEncoding:   "gzip, deflate, br",
+			expectedStatus:   http.StatusOK,
+			expectedBody:     "brotli",
+			expectedCache:    cacheImmutable,
+			expectedEncoding: "br",
+			expectedType:     "text/javascript; charset=utf-8",
+		},
+		{
+			name:             "GzipWhenBrotliRefused",
+			path:             "/assets/index-abc123.js",
+			acceptEncoding:   "br;q=0, gzip",
+			expectedStatus:   http.StatusOK,
+			expectedBody:     "gzip",
+			expectedCache:    cacheImmutable,
+			expectedEncoding: "gzip",
+			expectedType:     "text/javascript; charset=utf-8",
+		},
+		{
+			name:           "UnhashedFile",
+			path:           "/favicon.svg",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<svg/>",
+			expectedCache:  cacheRevalidate,
+			expectedType:   "image/svg+xml",
+		},
+		{
+			name:           "MissingAsset",
+			path:           "/assets/index-old999.js",
+			expectedStatus: http.StatusNotFound,
+			expectedCache:  cacheRevalidate,
+		},
+		{
+			name:           "MissingFileOutsideAssets",
+			path:           "/robots.txt",
+			expectedStatus: http.StatusNotFound,
+			expectedCache:  cacheRevalidate,
+		},
+		{
+			name:           "AssetDirectory",
+			path:           "/assets/",
+			expectedStatus: http.StatusNotFound,
+			expectedCache:  cacheRevalidate,
+		},
+	}
+
+	handler := SPAHandler(testDist)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+
+			handler(w, req)
+
+			// Assert the right file was served with the right headers
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tc.expectedCache {
+				t.Errorf("Expected Cache-Control %q, got %q", tc.expectedCache, got)
+			}
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			if w.Body.String() != tc.expectedBody {
+				t.Errorf("Expected body %q, got %q", tc.expectedBody, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Encoding"); got != tc.expectedEncoding {
+				t.Errorf("Expected Content-Encoding %q, got %q", tc.expectedEncoding, got)
+			}
+			if got := w.Header().Get("Content-Type"); got != tc.expectedType {
+				t.Errorf("Expected Content-Type %q, got %q", tc.expectedType, got)
+			}
+		})
+	}
+}
+
+func TestSPAHandler_Revalidation(t *testing.T) {
+	handler := SPAHandler(testDist)
+
+	// Create a first request to learn the ETag
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+
+	handler(w, req)
+
+	// Assert an unchanged index is not sent again
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected status %d, got %d", http.StatusNotModified, w.Code)
+	}
+}
+
+func TestSPAHandler_InjectsNonce(t *testing.T) {
+	dist := fstest.MapFS{
+		"index.html": {Data: []byte(`<script type="module" nonce="` + NoncePlaceholder + `" src="/assets/app.js"></script>`)},
+	}
+
+	// Create a request that passed through SecurityHeaders
+	var req *http.Request
+	middleware.SecurityHeaders(middleware.SecurityOptions{CSP: "script-src 'nonce-" + middleware.NonceToken + "'"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { req = r }),
+	).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+	nonce := middleware.CSPNonce(req.Context())
+
+	w := httptest.NewRecorder()
+	SPAHandler(dist)(w, req)
+
+	// Assert the nonce replaced the placeholder and the page is not cached
+	if !strings.Contains(w.Body.String(), `nonce="`+nonce+`"`) {
+		t.Errorf("Expected nonce %q in body, got %q", nonce, w.Body.String())
+	}
+	if got := w.Header().Get("Cache-Control"); got != cacheNever {
+		t.Errorf("Expected Cache-Control %q, got %q", cacheNever, got)
+	}
+	if w.Header().Get("ETag") != "" {
+		t.Error("Expected no ETag on a page with a nonce")
+	}
+
+	// Assert a request without a nonce still gets the page
+	w = httptest.NewRecorder()
+	SPAHandler(dist)(w, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(context.Background()))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
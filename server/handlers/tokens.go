@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fif/apitoken"
+	"fif/middleware"
+	"fif/problem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// Token lifetimes, in days
+const (
+	defaultTokenDays = 90
+	maxTokenDays     = 365
+)
+
+// maxTokenNameLength keeps token names to something that fits in a list
+const maxTokenNameLength = 100
+
+// APITokenStore manages a user's personal access tokens
+type APITokenStore interface {
+	Create(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (string, apitoken.Token, error)
+	List(ctx context.Context, userID string) ([]apitoken.Token, error)
+	Revoke(ctx context.Context, userID, id string) error
+}
+
+// CreatedAPITokenDTO is returned once when a token is created; it is the only
+// time the secret is shown
+type CreatedAPITokenDTO struct {
+	apitoken.Token
+	Secret string `json:"token"`
+}
+
+// MakeListAPITokensHandler creates a handler that lists the user's tokens
+func MakeListAPITokensHandler(store APITokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		tokens, err := store.List(r.Context(), token.UID)
+		if err != nil {
+			problem.Internal(w, r, "Error listing api tokens", err)
+			return
+		}
+
+		writeJSON(w, tokens)
+	}
+}
+
+// MakeCreateAPITokenHandler creates a handler that issues a token from
+// {"name": ..., "scopes": [...], "expiresInDays": n}
+func MakeCreateAPITokenHandler(store APITokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		var req struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays *int     `json:"expiresInDays"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "body", Message: "must be a JSON token request"})
+			return
+		}
+
+		days := defaultTokenDays
+		if req.ExpiresInDays != nil {
+			days = *req.ExpiresInDays
+		}
+
+		var errs []problem.FieldError
+		if req.Name == "" || len(req.Name) > maxTokenNameLength {
+			errs = append(errs, problem.FieldError{Field: "name", Message: fmt.Sprintf("must be 1 to %d characters", maxTokenNameLength)})
+		}
+		if len(req.Scopes) == 0 {
+			errs = append(errs, problem.FieldError{Field: "scopes", Message: "must name at least one scope"})
+		}
+		for i, scope := range req.Scopes {
+			if !apitoken.ValidScope(scope) {
+				errs = append(errs, problem.FieldError{Field: fmt.Sprintf("scopes[%d]", i), Message: fmt.Sprintf("unknown scope %q", scope)})
+			}
+		}
+		if days < 1 || days > maxTokenDays {
+			errs = append(errs, problem.FieldError{Field: "expiresInDays", Message: fmt.Sprintf("must be between 1 and %d", maxTokenDays)})
+		}
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+
+		expiresAt := time.Now().AddDate(0, 0, days)
+		secret, created, err := store.Create(r.Context(), token.UID, req.Name, req.Scopes, expiresAt)
+		if err != nil {
+			problem.Internal(w, r, "Error creating api token", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(CreatedAPITokenDTO{Token: created, Secret: secret}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// MakeRevokeAPITokenHandler creates a handler that revokes the token whose id
+// is in the URL
+func MakeRevokeAPITokenHandler(store APITokenStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		err := store.Revoke(r.Context(), token.UID, chi.URLParam(r, "id"))
+		if errors.Is(err, apitoken.ErrNotFound) {
+			problem.NotFound(w, r, "api token not found")
+			return
+		}
+		if err != nil {
+			problem.Internal(w, r, "Error revoking api token", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
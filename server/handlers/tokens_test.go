@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fif/apitoken"
+	"fif/middleware"
+	"fif/problem"
+	"fif/problem/problemtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// mockAPITokenStore is a mock implementation of the APITokenStore interface for testing
+type mockAPITokenStore struct {
+	created   []string
+	expiresAt time.Time
+}
+
+func (m *mockAPITokenStore) Create(ctx context.Context, userID, name string, scopes []string, expiresAt time.Time) (string, apitoken.Token, error) {
+	m.created = append(m.created, name)
+	m.expiresAt = expiresAt
+	return "fif_pat_secret", apitoken.Token{ID: "t1", Name: name, Scopes: scopes, ExpiresAt: expiresAt}, nil
+}
+
+func (m *mockAPITokenStore) List(ctx context.Context, userID string) ([]apitoken.Token, error) {
+	return []apitoken.Token{}, nil
+}
+
+func (m *mockAPITokenStore) Revoke(ctx context.Context, userID, id string) error {
+	if id != "t1" {
+		return apitoken.ErrNotFound
+	}
+	return nil
+}
+
+func TestCreateAPITokenHandler(t *testing.T) {
+	// Create a request for a token with one known scope and the default expiry
+	store := &mockAPITokenStore{}
+	req := httptest.NewRequest(http.MethodPost, "/api/account/tokens",
+		strings.NewReader(`{"name": "sheets", "scopes": ["holdings:read"]}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.CtxTokenKey{}, &auth.Token{UID: "u1"}))
+	w := httptest.NewRecorder()
+
+	MakeCreateAPITokenHandler(store).ServeHTTP(w, req)
+
+	// Assert the secret is returned once with the token's description
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+
+	var resp CreatedAPITokenDTO
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Secret != "fif_pat_secret" || resp.Name != "sheets" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	// Assert the default lifetime was applied
+	days := time.Until(store.expiresAt).Hours() / 24
+	if days < defaultTokenDays-1 || days > defaultTokenDays {
+		t.Errorf("Expected expiry in %d days, got %.1f", defaultTokenDays, days)
+	}
+}
+
+func TestCreateAPITokenHandler_Invalid(t *testing.T) {
+	// Create a request with an unknown scope and too long a lifetime
+	store := &mockAPITokenStore{}
+	req := httptest.NewRequest(http.MethodPost, "/api/account/tokens",
+		strings.NewReader(`{"name": "sheets", "scopes": ["admin"], "expiresInDays": 1000}`))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.CtxTokenKey{}, &auth.Token{UID: "u1"}))
+	w := httptest.NewRecorder()
+
+	MakeCreateAPITokenHandler(store).ServeHTTP(w, req)
+
+	// Assert nothing was created and both fields are reported
+	if len(store.created) != 0 {
+		t.Errorf("Expected no token to be created, got %v", store.created)
+	}
+
+	var resp problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Code != problem.CodeValidationFailed || len(resp.Errors) != 2 {
+		t.Errorf("Expected two validation errors, got %+v", resp)
+	}
+}
+
+func TestRevokeAPITokenHandler_NotFound(t *testing.T) {
+	// Create a request to revoke a token that does not belong to the user
+	req := httptest.NewRequest(http.MethodDelete, "/api/account/tokens/other", nil)
+	req = req.WithContext(context.WithValue(req.Context(), middleware.CtxTokenKey{}, &auth.Token{UID: "u1"}))
+	w := httptest.NewRecorder()
+
+	MakeRevokeAPITokenHandler(&mockAPITokenStore{}).ServeHTTP(w, req)
+
+	// Assert status code
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	problemtest.Assert(t, w, problem.CodeNotFound)
+}
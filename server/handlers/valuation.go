@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fif/middleware"
+	"fif/portfolio"
+	"fif/problem"
+	"fif/tax"
+	"net/http"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// MakeValuationHandler creates a handler that values the user's holdings on the
+// date given by the optional ?date=YYYY-MM-DD query parameter (default today in
+// New Zealand)
+func MakeValuationHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		date, err := parseDateParam(r, "date", tax.Date(time.Now()))
+		if err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "date", Message: dateFormatMessage})
+			return
+		}
+
+		valuation, err := portfolio.Load(r.Context(), db, token.UID, date)
+		if err != nil {
+			problem.Internal(w, r, "Error valuing portfolio", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(valuation); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// dateFormatMessage explains the expected format of date parameters
+const dateFormatMessage = "must be a date in YYYY-MM-DD format"
+
+// parseDateParam reads a YYYY-MM-DD query parameter, returning def when it is absent
+func parseDateParam(r *http.Request, name string, def time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	return time.Parse(portfolio.DateLayout, value)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fif/health"
+	"fif/migrations"
+	"fmt"
+)
+
+// newHealthChecker registers the dependencies /readyz reports on
+func newHealthChecker(db *sql.DB, authCheck health.CheckFunc) *health.Checker {
+	checker := health.NewChecker(health.DefaultTimeout)
+
+	checker.Add("database", db.PingContext)
+
+	checker.Add("migrations", func(ctx context.Context) error {
+		current, err := migrations.Current(ctx, db)
+		if err != nil {
+			return err
+		}
+		// A newer schema is expected while a rolling deploy replaces this
+		// replica, so only a schema this build has not reached is a failure
+		if want := migrations.Latest(); current < want {
+			return fmt.Errorf("schema is at version %d, expected at least %d", current, want)
+		}
+		return nil
+	})
+
+	checker.Add("auth", authCheck)
+
+	return checker
+}
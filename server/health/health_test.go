@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReadyz(t *testing.T) {
+	testCases := []struct {
+		name           string
+		database       CheckFunc
+		expectedStatus int
+		expectedDB     string
+	}{
+		{
+			name:           "AllHealthy",
+			database:       func(ctx context.Context) error { return nil },
+			expectedStatus: http.StatusOK,
+			expectedDB:     StatusOK,
+		},
+		{
+			name:           "DatabaseDown",
+			database:       func(ctx context.Context) error { return errors.New("connection refused") },
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedDB:     StatusUnavailable,
+		},
+		{
+			name: "DatabaseHangs",
+			database: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedDB:     StatusUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a checker with a healthy auth check and the database check under test
+			c := NewChecker(50 * time.Millisecond)
+			c.Add("auth", func(ctx context.Context) error { return nil })
+			c.Add("database", tc.database)
+
+			w := httptest.NewRecorder()
+			c.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			var report Report
+			if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			// Assert each dependency is reported separately
+			if report.Checks["database"].Status != tc.expectedDB {
+				t.Errorf("Expected database %s, got %+v", tc.expectedDB, report.Checks["database"])
+			}
+			if report.Checks["auth"].Status != StatusOK {
+				t.Errorf("Expected auth ok, got %+v", report.Checks["auth"])
+			}
+		})
+	}
+}
+
+func TestLivez(t *testing.T) {
+	// Create a checker whose only check fails
+	c := NewChecker(DefaultTimeout)
+	c.Add("database", func(ctx context.Context) error { return errors.New("down") })
+
+	w := httptest.NewRecorder()
+	c.Livez(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	// Assert liveness does not depend on checks
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestReadyz_ShuttingDown(t *testing.T) {
+	// Create a checker whose checks all pass, then begin shutdown
+	c := NewChecker(DefaultTimeout)
+	c.Add("database", func(ctx context.Context) error { return nil })
+	c.SetShuttingDown()
+
+	w := httptest.NewRecorder()
+	c.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	// Assert readiness fails regardless of the checks
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	var report Report
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if report.Status != StatusShuttingDown {
+		t.Errorf("Expected status %s, got %s", StatusShuttingDown, report.Status)
+	}
+}
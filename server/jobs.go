@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fif/demo"
+	"fif/jobs"
+	"fif/notify"
+	"fif/portfolio"
+	"fif/ratelimit"
+	"fif/tax"
+	"time"
+)
+
+// registerJobs adds the server's background jobs to the scheduler
+func registerJobs(scheduler *jobs.Scheduler, db *sql.DB, notifier *notify.Notifier, backfills *portfolio.BackfillQueue, demoUserID string) {
+	// Record today's valuation, dated in New Zealand, for every portfolio; later
+	// runs replace earlier ones
+	must(scheduler.Register("portfolio-snapshots", "5 * * * *", 30*time.Minute, func(ctx context.Context) error {
+		_, err := portfolio.SnapshotAll(ctx, db, tax.Date(time.Now()))
+		return err
+	}))
+
+	// Run history backfills queued through the API. Queueing also triggers
+	// the job, so the schedule only catches backfills that could not start then.
+	must(scheduler.Register(portfolio.BackfillJob, "*/5 * * * *", 0, func(ctx context.Context) error {
+		_, err := backfills.Run(ctx)
+		return err
+	}))
+
+	// Evaluate threshold and deadline alerts each morning in New Zealand
+	must(scheduler.Register("notifications", "0 20 * * *", 30*time.Minute, notifier.Run))
+
+	// Forget shared rate limit buckets that have long since refilled
+	must(scheduler.Register("rate-limit-cleanup", "@hourly", 0, func(ctx context.Context) error {
+		return ratelimit.NewPostgresStore(db).Cleanup(ctx, time.Hour)
+	}))
+
+	// Keep the demo portfolio's history running up to today
+	if demoUserID != "" {
+		must(scheduler.Register("demo-refresh", "30 0 * * *", 30*time.Minute, func(ctx context.Context) error {
+			_, err := demo.Generate(ctx, db, demoUserID, demo.Options{})
+			return err
+		}))
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		fatal("error registering job", err)
+	}
+}
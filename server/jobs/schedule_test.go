@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func mustTime(t *testing.T, s string) time.Time {
+	t.Helper()
+	tm, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatalf("Failed to parse time %q: %v", s, err)
+	}
+	return tm
+}
+
+func TestSchedule_Next(t *testing.T) {
+	testCases := []struct {
+		name     string
+		spec     string
+		from     string
+		expected string
+	}{
+		{name: "EveryMinute", spec: "* * * * *", from: "2025-03-31T10:15:30Z", expected: "2025-03-31T10:16:00Z"},
+		{name: "Hourly", spec: "@hourly", from: "2025-03-31T10:15:00Z", expected: "2025-03-31T11:00:00Z"},
+		{name: "Step", spec: "*/15 * * * *", from: "2025-03-31T10:15:00Z", expected: "2025-03-31T10:30:00Z"},
+		{name: "DailyNextDay", spec: "30 2 * * *", from: "2025-03-31T03:00:00Z", expected: "2025-04-01T02:30:00Z"},
+		{name: "EndOfYear", spec: "@daily", from: "2025-12-31T23:59:00Z", expected: "2026-01-01T00:00:00Z"},
+		{name: "Weekdays", spec: "0 9 * * 1-5", from: "2025-04-04T10:00:00Z", expected: "2025-04-07T09:00:00Z"}, // Friday to Monday
+		{name: "SundayAsSeven", spec: "0 0 * * 7", from: "2025-04-01T00:00:00Z", expected: "2025-04-06T00:00:00Z"},
+		{name: "List", spec: "0 6,18 * * *", from: "2025-03-31T07:00:00Z", expected: "2025-03-31T18:00:00Z"},
+		{name: "MonthAndDay", spec: "0 0 7 7 *", from: "2025-07-08T00:00:00Z", expected: "2026-07-07T00:00:00Z"},
+		{name: "DayOfMonthOrWeek", spec: "0 0 15 * 1", from: "2025-04-08T00:00:00Z", expected: "2025-04-14T00:00:00Z"},       // Monday before the 15th
+		{name: "DayOfMonthStepAndWeek", spec: "0 0 */2 * 1", from: "2025-04-01T00:00:00Z", expected: "2025-04-07T00:00:00Z"}, // odd days that are Mondays
+		{name: "LeapDay", spec: "0 0 29 2 *", from: "2025-01-01T00:00:00Z", expected: "2028-02-29T00:00:00Z"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseSchedule(tc.spec)
+			if err != nil {
+				t.Fatalf("Unexpected error parsing %q: %v", tc.spec, err)
+			}
+
+			next := s.Next(mustTime(t, tc.from))
+			if !next.Equal(mustTime(t, tc.expected)) {
+				t.Errorf("Expected %s, got %s", tc.expected, next.Format(time.RFC3339))
+			}
+		})
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	specs := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"@fortnightly",
+	}
+
+	for _, spec := range specs {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fif/metrics"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds a run, and therefore its lease, when a job sets none
+const DefaultTimeout = time.Hour
+
+// ErrUnknownJob is returned when triggering a job that was never registered
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrStopped is returned when triggering a job after Shutdown has begun
+var ErrStopped = errors.New("scheduler is shutting down")
+
+// Func is the work a job performs
+type Func func(ctx context.Context) error
+
+// Job is a named unit of background work run on a schedule
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Timeout  time.Duration
+	Run      Func
+}
+
+// JobStatus describes a registered job for the admin API
+type JobStatus struct {
+	Name     string    `json:"name"`
+	Schedule string    `json:"schedule"`
+	NextRun  time.Time `json:"nextRun"`
+	Running  bool      `json:"running"`
+	LastRun  *Run      `json:"lastRun,omitempty"`
+}
+
+// Scheduler runs registered jobs on their schedules. Before each run it takes a
+// lease in the Store, so with several replicas only one runs a given slot.
+type Scheduler struct {
+	store  Store
+	holder string
+	now    func() time.Time
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	running map[string]bool
+	baseCtx context.Context
+	wg      sync.WaitGroup
+
+	stopping   bool
+	stopLoops  context.CancelFunc
+	cancelRuns context.CancelFunc
+}
+
+// NewScheduler creates a Scheduler that coordinates through store
+func NewScheduler(store Store) *Scheduler {
+	return &Scheduler{
+		store:   store,
+		holder:  newHolderID(),
+		now:     time.Now,
+		jobs:    map[string]*Job{},
+		running: map[string]bool{},
+		baseCtx: context.Background(),
+	}
+}
+
+// Register adds a job with a cron schedule. A zero timeout uses DefaultTimeout.
+func (s *Scheduler) Register(name, spec string, timeout time.Duration, fn Func) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %q already registered", name)
+	}
+	s.jobs[name] = &Job{Name: name, Schedule: schedule, Timeout: timeout, Run: fn}
+	s.order = append(s.order, name)
+	return nil
+}
+
+// Start runs each job on its schedule until ctx is cancelled or Shutdown is
+// called
+func (s *Scheduler) Start(ctx context.Context) {
+	loopCtx, stopLoops := context.WithCancel(ctx)
+	runCtx, cancelRuns := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.baseCtx = runCtx
+	s.stopLoops, s.cancelRuns = stopLoops, cancelRuns
+	jobs := make([]*Job, 0, len(s.order))
+	for _, name := range s.order {
+		jobs = append(jobs, s.jobs[name])
+	}
+	s.mu.Unlock()
+
+	for _, job := range jobs {
+		go s.loop(loopCtx, job)
+	}
+}
+
+// Shutdown stops scheduling and triggering runs and waits for those in
+// progress. If ctx ends first, the runs are cancelled and ctx's error is
+// returned without waiting for them to notice.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.stopping = true
+	stopLoops, cancelRuns := s.stopLoops, s.cancelRuns
+	s.mu.Unlock()
+
+	if stopLoops != nil {
+		stopLoops()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if cancelRuns != nil {
+			cancelRuns()
+		}
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until every run that has started has finished
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Trigger starts a run of the named job now. It returns false if the job is
+// already running here or another replica holds its lease.
+func (s *Scheduler) Trigger(ctx context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	job, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return false, ErrUnknownJob
+	}
+	return s.start(ctx, job, s.now().UTC(), TriggerManual)
+}
+
+// Status lists registered jobs with their next scheduled time and last run
+func (s *Scheduler) Status(ctx context.Context) ([]JobStatus, error) {
+	last, err := s.store.LastRuns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	statuses := make([]JobStatus, 0, len(s.order))
+	for _, name := range s.order {
+		job := s.jobs[name]
+		st := JobStatus{
+			Name:     name,
+			Schedule: job.Schedule.String(),
+			NextRun:  job.Schedule.Next(now),
+			Running:  s.running[name],
+		}
+		if r, ok := last[name]; ok {
+			st.LastRun = &r
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// Runs returns recent run history for the named job
+func (s *Scheduler) Runs(ctx context.Context, name string, limit int) ([]Run, error) {
+	s.mu.Lock()
+	_, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return s.store.Runs(ctx, name, limit)
+}
+
+func (s *Scheduler) loop(ctx context.Context, job *Job) {
+	for {
+		slot := job.Schedule.Next(s.now())
+		if slot.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(slot.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.start(ctx, job, slot, TriggerSchedule); err != nil {
+			slog.Error("Error starting job", "job", job.Name, "error", err)
+		}
+	}
+}
+
+// start takes the job's lease for slot and, if successful, runs it in the background
+func (s *Scheduler) start(ctx context.Context, job *Job, slot time.Time, trigger string) (bool, error) {
+	s.mu.Lock()
+	if s.stopping {
+		s.mu.Unlock()
+		return false, ErrStopped
+	}
+	if s.running[job.Name] {
+		s.mu.Unlock()
+		return false, nil
+	}
+	s.running[job.Name] = true
+	baseCtx := s.baseCtx
+	// Added under the lock so Shutdown never waits on a run it did not see
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	acquired, err := s.store.AcquireLease(ctx, job.Name, s.holder, slot, job.Timeout)
+	if err != nil || !acquired {
+		s.setRunning(job.Name, false)
+		s.wg.Done()
+		return false, err
+	}
+
+	go func() {
+		defer s.wg.Done()
+		defer s.setRunning(job.Name, false)
+		s.run(baseCtx, job, trigger)
+	}()
+	return true, nil
+}
+
+func (s *Scheduler) run(ctx context.Context, job *Job, trigger string) {
+	// Bookkeeping uses a fresh context so a cancelled run is still recorded
+	bookkeeping := context.WithoutCancel(ctx)
+
+	id, err := s.store.StartRun(bookkeeping, job.Name, s.holder, trigger)
+	if err != nil {
+		slog.Error("Error recording start of job", "job", job.Name, "error", err)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	started := s.now()
+	runErr := safeRun(runCtx, job.Run)
+	cancel()
+
+	status := StatusSucceeded
+	if runErr != nil {
+		status = StatusFailed
+	}
+	metrics.JobRuns.WithLabelValues(job.Name, status).Inc()
+	metrics.JobDuration.WithLabelValues(job.Name).Observe(s.now().Sub(started).Seconds())
+
+	if runErr != nil {
+		slog.Error("Job failed", "job", job.Name, "trigger", trigger, "duration", s.now().Sub(started), "error", runErr)
+	} else {
+		slog.Info("Job succeeded", "job", job.Name, "trigger", trigger, "duration", s.now().Sub(started))
+	}
+
+	if id != 0 {
+		if err := s.store.FinishRun(bookkeeping, id, runErr); err != nil {
+			slog.Error("Error recording end of job", "job", job.Name, "error", err)
+		}
+	}
+	if err := s.store.ReleaseLease(bookkeeping, job.Name, s.holder); err != nil {
+		slog.Error("Error releasing job lease", "job", job.Name, "error", err)
+	}
+}
+
+func (s *Scheduler) setRunning(name string, running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.running[name] = running
+}
+
+// safeRun turns a panicking job into a failed run instead of crashing the server
+func safeRun(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// newHolderID identifies this process when taking leases
+func newHolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
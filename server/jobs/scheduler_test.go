@@ -0,0 +1,229 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore is an in-memory Store for testing
+type memoryStore struct {
+	mu       sync.Mutex
+	leased   map[string]bool
+	runs     []Run
+	acquired int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{leased: map[string]bool{}}
+}
+
+func (m *memoryStore) AcquireLease(ctx context.Context, job, holder string, slot time.Time, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.leased[job] {
+		return false, nil
+	}
+	m.leased[job] = true
+	m.acquired++
+	return true, nil
+}
+
+func (m *memoryStore) ReleaseLease(ctx context.Context, job, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.leased[job] = false
+	return nil
+}
+
+func (m *memoryStore) StartRun(ctx context.Context, job, holder, trigger string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, Run{ID: int64(len(m.runs) + 1), Job: job, Holder: holder, Trigger: trigger, Status: StatusRunning})
+	return int64(len(m.runs)), nil
+}
+
+func (m *memoryStore) FinishRun(ctx context.Context, id int64, runErr error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &m.runs[id-1]
+	r.Status = StatusSucceeded
+	if runErr != nil {
+		r.Status, r.Error = StatusFailed, runErr.Error()
+	}
+	return nil
+}
+
+func (m *memoryStore) LastRuns(ctx context.Context) (map[string]Run, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := map[string]Run{}
+	for _, r := range m.runs {
+		last[r.Job] = r
+	}
+	return last, nil
+}
+
+func (m *memoryStore) Runs(ctx context.Context, job string, limit int) ([]Run, error) {
+	return nil, nil
+}
+
+func TestScheduler_TriggerRecordsRun(t *testing.T) {
+	store := newMemoryStore()
+	s := NewScheduler(store)
+
+	ran := false
+	if err := s.Register("test", "@daily", 0, func(ctx context.Context) error {
+		ran = true
+		return nil
+	}); err != nil {
+		t.Fatalf("Unexpected error registering job: %v", err)
+	}
+
+	started, err := s.Trigger(context.Background(), "test")
+	if err != nil || !started {
+		t.Fatalf("Expected job to start, got started=%v err=%v", started, err)
+	}
+	s.Wait()
+
+	if !ran {
+		t.Error("Expected job function to run")
+	}
+
+	statuses, err := s.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(statuses) != 1 || statuses[0].LastRun == nil {
+		t.Fatalf("Expected one job with a last run, got %+v", statuses)
+	}
+	if statuses[0].LastRun.Status != StatusSucceeded || statuses[0].LastRun.Trigger != TriggerManual {
+		t.Errorf("Expected succeeded manual run, got %+v", statuses[0].LastRun)
+	}
+	if store.leased["test"] {
+		t.Error("Expected lease to be released after the run")
+	}
+}
+
+func TestScheduler_FailureAndPanicRecorded(t *testing.T) {
+	store := newMemoryStore()
+	s := NewScheduler(store)
+
+	s.Register("fails", "@daily", 0, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	s.Register("panics", "@daily", 0, func(ctx context.Context) error {
+		panic("oops")
+	})
+
+	s.Trigger(context.Background(), "fails")
+	s.Trigger(context.Background(), "panics")
+	s.Wait()
+
+	last, _ := store.LastRuns(context.Background())
+	if last["fails"].Status != StatusFailed || last["fails"].Error != "boom" {
+		t.Errorf("Expected failed run with error boom, got %+v", last["fails"])
+	}
+	if last["panics"].Status != StatusFailed || last["panics"].Error != "panic: oops" {
+		t.Errorf("Expected failed run recording the panic, got %+v", last["panics"])
+	}
+}
+
+func TestScheduler_TriggerWhileLeased(t *testing.T) {
+	store := newMemoryStore()
+	s := NewScheduler(store)
+	s.Register("test", "@daily", 0, func(ctx context.Context) error { return nil })
+
+	// Another replica holds the lease
+	store.leased["test"] = true
+
+	started, err := s.Trigger(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if started {
+		t.Error("Expected job not to start while another holder has the lease")
+	}
+}
+
+func TestScheduler_TriggerUnknownJob(t *testing.T) {
+	s := NewScheduler(newMemoryStore())
+
+	if _, err := s.Trigger(context.Background(), "missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+}
+
+func TestScheduler_RegisterDuplicate(t *testing.T) {
+	s := NewScheduler(newMemoryStore())
+	fn := func(ctx context.Context) error { return nil }
+
+	if err := s.Register("test", "@daily", 0, fn); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := s.Register("test", "@daily", 0, fn); err == nil {
+		t.Error("Expected error registering a duplicate job")
+	}
+	if err := s.Register("bad", "not a schedule", 0, fn); err == nil {
+		t.Error("Expected error registering an invalid schedule")
+	}
+}
+
+func TestScheduler_ShutdownWaitsForRuns(t *testing.T) {
+	s := NewScheduler(newMemoryStore())
+
+	release := make(chan struct{})
+	finished := false
+	s.Register("slow", "@daily", 0, func(ctx context.Context) error {
+		<-release
+		finished = true
+		return nil
+	})
+	s.Start(context.Background())
+	if started, err := s.Trigger(context.Background(), "slow"); err != nil || !started {
+		t.Fatalf("Expected job to start, got started=%v err=%v", started, err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Assert the run finished and no new runs are accepted
+	if !finished {
+		t.Error("Expected Shutdown to wait for the run")
+	}
+	if _, err := s.Trigger(context.Background(), "slow"); !errors.Is(err, ErrStopped) {
+		t.Errorf("Expected ErrStopped, got %v", err)
+	}
+}
+
+func TestScheduler_ShutdownDeadlineCancelsRuns(t *testing.T) {
+	store := newMemoryStore()
+	s := NewScheduler(store)
+
+	s.Register("stuck", "@daily", 0, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	s.Start(context.Background())
+	s.Trigger(context.Background(), "stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline exceeded, got %v", err)
+	}
+	s.Wait()
+
+	// Assert the cancelled run is recorded as failed
+	last, _ := store.LastRuns(context.Background())
+	if last["stuck"].Status != StatusFailed {
+		t.Errorf("Expected failed run, got %+v", last["stuck"])
+	}
+}
@@ -0,0 +1,171 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Run statuses recorded in job run history
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Triggers recorded in job run history
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Run is one execution of a job
+type Run struct {
+	ID         int64      `json:"id"`
+	Job        string     `json:"job"`
+	Holder     string     `json:"holder"`
+	Trigger    string     `json:"trigger"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Store persists leases and run history so replicas can coordinate
+type Store interface {
+	// AcquireLease claims a job for holder until ttl elapses. It fails if another
+	// holder has an unexpired lease or the slot has already been run.
+	AcquireLease(ctx context.Context, job, holder string, slot time.Time, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, job, holder string) error
+	StartRun(ctx context.Context, job, holder, trigger string) (int64, error)
+	FinishRun(ctx context.Context, id int64, runErr error) error
+	// LastRuns returns the most recent run of each job
+	LastRuns(ctx context.Context) (map[string]Run, error)
+	// Runs returns the most recent runs of one job, newest first
+	Runs(ctx context.Context, job string, limit int) ([]Run, error)
+}
+
+// PostgresStore implements Store using the job_leases and job_runs tables
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a Store backed by db
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) AcquireLease(ctx context.Context, job, holder string, slot time.Time, ttl time.Duration) (bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+		INSERT INTO job_leases (name, holder, expires_at, slot)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3), $4)
+		ON CONFLICT (name) DO UPDATE
+			SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at, slot = EXCLUDED.slot
+			WHERE job_leases.expires_at < NOW() AND job_leases.slot < EXCLUDED.slot
+		RETURNING name
+	`, job, holder, ttl.Seconds(), slot)
+
+	var name string
+	switch err := row.Scan(&name); err {
+	case nil:
+		return true, nil
+	case sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, fmt.Errorf("failed to acquire lease for %s: %w", job, err)
+	}
+}
+
+func (s *PostgresStore) ReleaseLease(ctx context.Context, job, holder string) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE job_leases SET expires_at = NOW()
+		WHERE name = $1 AND holder = $2
+	`, job, holder); err != nil {
+		return fmt.Errorf("failed to release lease for %s: %w", job, err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) StartRun(ctx context.Context, job, holder, trigger string) (int64, error) {
+	var id int64
+	if err := s.db.QueryRowContext(ctx, `
+		INSERT INTO job_runs (name, holder, trigger, status)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, job, holder, trigger, StatusRunning).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to record run for %s: %w", job, err)
+	}
+	return id, nil
+}
+
+func (s *PostgresStore) FinishRun(ctx context.Context, id int64, runErr error) error {
+	status, message := StatusSucceeded, ""
+	if runErr != nil {
+		status, message = StatusFailed, runErr.Error()
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE job_runs SET status = $2, error = NULLIF($3, ''), finished_at = NOW()
+		WHERE id = $1
+	`, id, status, message); err != nil {
+		return fmt.Errorf("failed to finish run %d: %w", id, err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) LastRuns(ctx context.Context) (map[string]Run, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (name) id, name, holder, trigger, status, COALESCE(error, ''), started_at, finished_at
+		FROM job_runs
+		ORDER BY name, started_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+
+	runs, err := scanRuns(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	last := map[string]Run{}
+	for _, r := range runs {
+		last[r.Job] = r
+	}
+	return last, nil
+}
+
+func (s *PostgresStore) Runs(ctx context.Context, job string, limit int) ([]Run, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, holder, trigger, status, COALESCE(error, ''), started_at, finished_at
+		FROM job_runs
+		WHERE name = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`, job, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+	return scanRuns(rows)
+}
+
+func scanRuns(rows *sql.Rows) ([]Run, error) {
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		var r Run
+		var finished sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Job, &r.Holder, &r.Trigger, &r.Status, &r.Error, &r.StartedAt, &finished); err != nil {
+			return nil, fmt.Errorf("failed to scan job run: %w", err)
+		}
+		if finished.Valid {
+			r.FinishedAt = &finished.Time
+		}
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate job runs: %w", err)
+	}
+	return runs, nil
+}
@@ -3,95 +3,309 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"fif/apitoken"
+	"fif/config"
+	"fif/flags"
 	"fif/handlers"
+	"fif/jobs"
+	"fif/logging"
+	"fif/metrics"
 	"fif/middleware"
+	"fif/migrations"
+	"fif/notify"
+	"fif/portfolio"
+	"fif/tracing"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
 //go:embed all:webdist/*
 var webdist embed.FS
 
-func getCORSOrigins() []string {
-	allowedOrigins := os.Getenv("ALLOWED_ORIGINS")
-	if allowedOrigins == "" {
-		log.Fatal("ALLOWED_ORIGINS environment variable is required")
+// serve runs the HTTP server until it receives SIGINT or SIGTERM
+func serve() {
+	cfg, err := config.Load()
+	if err != nil {
+		fatal("invalid configuration", err)
 	}
+	logging.Setup(cfg.Log.Format, cfg.Log.Level)
+	slog.LogAttrs(context.Background(), slog.LevelInfo, "Configuration", cfg.Summary()...)
 
-	origins := strings.Split(allowedOrigins, ",")
-	// Trim whitespace from each origin
-	for i := range origins {
-		origins[i] = strings.TrimSpace(origins[i])
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracesExporter)
+	if err != nil {
+		fatal("error configuring tracing", err)
 	}
-	return origins
-}
-
-func main() {
-	firebase, err := initFirebaseApp()
 
+	db, err := InitDB(context.Background(), cfg.Database)
 	if err != nil {
-		log.Fatalf("error initializing firebase app: %v\n", err)
+		fatal("error initializing database", err)
 	}
+	defer db.Close()
 
-	authClient, err := firebase.Auth(context.Background())
+	if cfg.Database.MigrateOnStart {
+		applied, err := migrations.Up(context.Background(), db)
+		if err != nil {
+			fatal("error migrating database", err)
+		}
+		if len(applied) > 0 {
+			slog.Info("Applied migrations", "versions", applied)
+		}
+	}
 
-	if err != nil {
-		log.Fatalf("error getting auth client: %v\n", err)
+	if err := metrics.RegisterDB(db, "postgres"); err != nil {
+		fatal("error registering database metrics", err)
 	}
+	metricsSrv := serveMetrics(cfg.Server)
+
+	apiTokens := apitoken.NewStore(db)
 
-	db, err := InitDB()
+	requireAuth, authCheck, err := newAuthMiddleware(cfg, middleware.WithAPITokens(apiTokens))
 	if err != nil {
-		log.Fatalf("error initializing database: %v\n", err)
+		fatal("error configuring auth", err)
 	}
-	defer db.Close()
 
-	origins := getCORSOrigins()
+	backfills := portfolio.NewBackfillQueue(db)
+
+	scheduler := jobs.NewScheduler(jobs.NewPostgresStore(db))
+	registerJobs(scheduler, db, notify.NewNotifier(db, newSender(cfg.SMTP)), backfills, cfg.Auth.DemoUserID)
+	scheduler.Start(context.Background())
+
+	limits := newRateLimits(db, cfg.RateLimit)
+
+	checker := newHealthChecker(db, authCheck)
+
+	featureFlags := flags.NewStore(db, cfg.Flags.Defaults, cfg.Flags.Refresh)
 
 	r := chi.NewRouter()
+	r.Use(tracing.Middleware)
+	r.Use(middleware.RequestID)
+	// Behind a load balancer the client IP, used for rate limiting
+	// unauthenticated requests, only arrives in X-Forwarded-For
+	if cfg.Server.TrustProxyHeaders {
+		r.Use(chimiddleware.RealIP)
+	}
+	r.Use(middleware.AccessLog(slog.Default()))
+	r.Use(middleware.SecurityHeaders(middleware.SecurityOptions{
+		HSTSMaxAge:     cfg.Security.HSTSMaxAge,
+		ReferrerPolicy: cfg.Security.ReferrerPolicy,
+		FrameAncestors: cfg.Security.FrameAncestors,
+		CSP:            cfg.Security.CSP,
+		CSPReportOnly:  cfg.Security.CSPReportOnly,
+		CSPReportURI:   cfg.Security.CSPReportURI,
+	}))
+	r.Use(metrics.Middleware)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   origins,
+		AllowedOrigins:   cfg.Server.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", middleware.RequestIDHeader, "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", middleware.RequestIDHeader},
 		AllowCredentials: true,
 	}))
 
 	r.Route("/api", func(r chi.Router) {
-		r.Get("/holdings", handlers.MakeHoldingsHandler(db))
-		r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"status":"ok"}`))
-		})
+		r.Use(middleware.Timeout(cfg.Server.RequestTimeout))
+
+		// Kept for existing monitors; same as /readyz, but public, so it is
+		// rate limited by IP like other unauthenticated requests
+		r.With(limits.group("preauth")).Get("/health", checker.Readyz)
+		// Browsers post Content Security Policy violations here
+		r.With(limits.group("public")).Post("/csp-report", handlers.CSPReportHandler)
 
-		// Protected routes (authentication required)
+		// Protected routes (authentication required). Personal access tokens
+		// may only read, and only with the matching scope.
 		r.Group(func(r chi.Router) {
-			r.Use(middleware.AuthMiddleware(authClient))
+			// Every token is checked against Firebase or the database, so
+			// requests are limited by IP before they are authenticated
+			r.Use(limits.group("preauth"))
+			r.Use(requireAuth)
+			r.Use(limits.group("api"))
+
+			r.With(middleware.RequireScope(middleware.ScopeAccountRead)).Get("/account", handlers.MakeAccountHandler(featureFlags))
+			r.With(middleware.RequireScope(middleware.ScopeAccountRead)).Get("/account/notifications", handlers.MakeGetNotificationSettingsHandler(db))
+
+			r.With(middleware.RequireScope(middleware.ScopeHoldingsRead)).Get("/holdings", handlers.MakeHoldingsHandler(db))
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireScope(middleware.ScopePortfolioRead))
+
+				r.Get("/portfolio/valuation", handlers.MakeValuationHandler(db))
+				r.Get("/portfolio/history", handlers.MakeHistoryHandler(db))
+				r.With(limits.group("expensive")).Get("/portfolio/returns", handlers.MakeReturnsHandler(db))
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireSession)
+
+				r.Put("/account/notifications", handlers.MakePutNotificationSettingsHandler(db))
+				r.With(limits.group("expensive")).Post("/portfolio/history/backfill", handlers.MakeBackfillHandler(backfills, scheduler))
+				r.Get("/portfolio/history/backfill/{id}", handlers.MakeGetBackfillHandler(backfills))
+
+				r.Get("/account/tokens", handlers.MakeListAPITokensHandler(apiTokens))
+				r.Post("/account/tokens", handlers.MakeCreateAPITokenHandler(apiTokens))
+				r.Delete("/account/tokens/{id}", handlers.MakeRevokeAPITokenHandler(apiTokens))
+			})
+		})
+
+		// Admin routes (authentication and the admin role required)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(limits.group("preauth"))
+			r.Use(requireAuth)
+			r.Use(middleware.RequireRole(middleware.NewDBRoleStore(db), middleware.RoleAdmin))
+			r.Use(limits.group("admin"))
+
+			r.Get("/users", handlers.MakeAdminUsersHandler(db))
+			r.Put("/users/{id}/roles", handlers.MakeSetUserRolesHandler(db))
+			r.Get("/import-failures", handlers.MakeImportFailuresHandler(db))
+
+			r.Get("/instruments", handlers.MakeListInstrumentsHandler(db))
+			r.Put("/instruments/{symbol}", handlers.MakePutInstrumentHandler(db))
+			r.Delete("/instruments/{symbol}", handlers.MakeDeleteInstrumentHandler(db))
+
+			r.Get("/fx-rates", handlers.MakeListFXRatesHandler(db))
+			r.Put("/fx-rates", handlers.MakePutFXRatesHandler(db))
+			r.Put("/prices", handlers.MakePutPricesHandler(db))
 
-			r.Get("/account", handlers.AccountHandler)
+			r.Get("/jobs", handlers.MakeJobsHandler(scheduler))
+			r.Get("/jobs/{name}/runs", handlers.MakeJobRunsHandler(scheduler))
+			r.Post("/jobs/{name}/run", handlers.MakeRunJobHandler(scheduler))
 
-			r.Get("/holdings", handlers.MakeHoldingsHandler(db))
+			r.Get("/flags", handlers.MakeListFlagsHandler(featureFlags))
+			r.Put("/flags/{name}", handlers.MakePutFlagHandler(featureFlags))
+			r.Delete("/flags/{name}", handlers.MakeDeleteFlagHandler(featureFlags))
 		})
 	})
 
+	// Probes for the orchestrator. They sit outside /api so they skip rate
+	// limiting and must be registered before the SPA catch-all.
+	r.Get("/livez", checker.Livez)
+	r.Get("/readyz", checker.Readyz)
+
+	// Runtime settings for the web app, in place of build-time VITE_ variables
+	r.Get("/config.json", handlers.MakeFrontendConfigHandler(frontendConfig(cfg), featureFlags))
+
 	// Static files and SPA fallback
 	distFS, err := fs.Sub(webdist, "webdist")
 	if err != nil {
-		log.Fatal(err)
+		fatal("error loading web assets", err)
 	}
 
 	r.Get("/*", handlers.SPAHandler(distFS))
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	srv := newHTTPServer(":"+cfg.Server.Port, r, cfg.Server)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "port", cfg.Server.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		fatal("server stopped", err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process without waiting
+	stop()
+
+	slog.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	checker.SetShuttingDown()
+	// Time for load balancers to see /readyz fail before the listener closes
+	time.Sleep(cfg.Server.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error draining requests", "error", err)
+	}
+	if err := scheduler.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error waiting for jobs", "error", err)
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Error stopping metrics server", "error", err)
+		}
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("Error flushing traces", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// serveMetrics exposes Prometheus metrics on the metrics address, away from
+// the public port. It returns nil when the address is "off".
+func serveMetrics(cfg config.ServerConfig) *http.Server {
+	if cfg.MetricsAddr == "off" {
+		return nil
 	}
 
-	log.Printf("Server starting on port %s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	srv := newHTTPServer(cfg.MetricsAddr, mux, cfg)
+
+	go func() {
+		slog.Info("Metrics listening", "addr", cfg.MetricsAddr)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			fatal("metrics server stopped", err)
+		}
+	}()
+	return srv
+}
+
+// newSender sends email over SMTP when a host is configured and writes it to
+// the outbox directory otherwise
+func newSender(cfg config.SMTPConfig) notify.Sender {
+	if cfg.Host == "" {
+		return &notify.LogSender{Dir: cfg.OutboxDir}
+	}
+	return &notify.SMTPSender{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	}
+}
+
+// frontendConfig picks the public settings the web app needs
+func frontendConfig(cfg *config.Config) handlers.FrontendConfig {
+	env := cfg.AppEnv
+	if env == "" {
+		env = "production"
+	}
+
+	return handlers.FrontendConfig{
+		APIURL:      cfg.Frontend.APIURL,
+		Environment: env,
+		Auth: handlers.FrontendAuth{
+			Provider:        cfg.Frontend.AuthProvider,
+			SupabaseURL:     cfg.Frontend.SupabaseURL,
+			SupabaseAnonKey: cfg.Frontend.SupabaseAnonKey,
+			RedirectURL:     cfg.Frontend.SupabaseRedirectURL,
+		},
+		Demo: cfg.Auth.DemoUserID != "",
+	}
+}
+
+// fatal logs msg with err and exits
+func fatal(msg string, err error) {
+	if err != nil {
+		slog.Error(msg, "error", err)
+	} else {
+		slog.Error(msg)
+	}
+	os.Exit(1)
 }
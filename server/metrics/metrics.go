@@ -0,0 +1,127 @@
+// Package metrics defines the server's Prometheus metrics and serves them
+// from a separate admin listener.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "fif"
+
+// Registry holds every metric the server exports
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestDuration observes request latency by route pattern
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, chi route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// AuthFailures counts rejected requests by reason
+	AuthFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Requests rejected by the auth middleware, by reason.",
+	}, []string{"reason"})
+
+	// AuthTokenCache counts verified token cache lookups by result (hit or miss)
+	AuthTokenCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_token_cache_lookups_total",
+		Help:      "Verified ID token cache lookups, by result.",
+	}, []string{"result"})
+
+	// AuthTokenCacheEvictions counts tokens dropped to keep the cache in bounds
+	AuthTokenCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_token_cache_evictions_total",
+		Help:      "Verified ID tokens evicted from the full cache.",
+	})
+
+	// JobRuns counts background job runs by outcome
+	JobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Background job runs, by job and status.",
+	}, []string{"job", "status"})
+
+	// JobDuration observes how long background jobs take
+	JobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Background job run time, by job.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 1800},
+	}, []string{"job"})
+
+	// CalculationDuration observes portfolio and tax calculations
+	CalculationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "calculation_duration_seconds",
+		Help:      "Time spent in portfolio and tax calculations, by calculation.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5},
+	}, []string{"calculation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		AuthFailures,
+		AuthTokenCache,
+		AuthTokenCacheEvictions,
+		JobRuns,
+		JobDuration,
+		CalculationDuration,
+	)
+}
+
+// RegisterDB exports connection pool statistics from db.Stats()
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// ObserveCalculation records how long a calculation took since start
+func ObserveCalculation(calculation string, start time.Time) {
+	CalculationDuration.WithLabelValues(calculation).Observe(time.Since(start).Seconds())
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware observes each request's latency, labelled with the chi route
+// pattern rather than the path so IDs in URLs do not explode cardinality
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+
+		HTTPRequestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMiddleware_LabelsByRoutePattern(t *testing.T) {
+	// Create a router with a parameterised route
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/api/admin/jobs/{name}/runs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	for _, name := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/admin/jobs/"+name+"/runs", nil))
+	}
+
+	// Assert both requests share one series labelled with the pattern
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	var count uint64
+	for _, family := range families {
+		if family.GetName() != "fif_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "route" && label.GetValue() == "/api/admin/jobs/{name}/runs" {
+					count += m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 observations for the route pattern, got %d", count)
+	}
+}
+
+func TestHandler_ExposesMetrics(t *testing.T) {
+	// Record a job run and an auth failure
+	JobRuns.WithLabelValues("portfolio-snapshots", "succeeded").Inc()
+	AuthFailures.WithLabelValues("expired").Inc()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	// Assert they appear in the exposition
+	body := w.Body.String()
+	for _, want := range []string{
+		`fif_job_runs_total{job="portfolio-snapshots",status="succeeded"} 1`,
+		`fif_auth_failures_total{reason="expired"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics output", want)
+		}
+	}
+}
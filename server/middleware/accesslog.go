@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// accessLogKey is the context key for the entry being built for a request
+type accessLogKey struct{}
+
+// accessLogEntry collects details learnt while handling a request. Handlers
+// run with a derived context, so they write here instead.
+type accessLogEntry struct {
+	uid string
+}
+
+// setAccessLogUID records the authenticated user for the access log
+func setAccessLogUID(ctx context.Context, uid string) {
+	if entry, ok := ctx.Value(accessLogKey{}).(*accessLogEntry); ok {
+		entry.uid = uid
+	}
+}
+
+// AccessLog logs one line per request with the method, route pattern,
+// status, size, latency and user. The query string and headers are left out
+// so credentials never reach the logs.
+func AccessLog(logger *slog.Logger) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			entry := &accessLogEntry{}
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			ctx := context.WithValue(r.Context(), accessLogKey{}, entry)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			route := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+
+			level := slog.LevelInfo
+			if status >= 500 {
+				level = slog.LevelError
+			}
+
+			logger.LogAttrs(ctx, level, "request",
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("uid", entry.uid),
+				slog.String("remote_ip", remoteIP(r)),
+			)
+		})
+	}
+}
+
+// remoteIP is the client address without its port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
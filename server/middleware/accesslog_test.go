@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "Honoured", incoming: "abc-123", keep: true},
+		{name: "Missing", incoming: ""},
+		{name: "Unsafe", incoming: "abc\n123"},
+		{name: "TooLong", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = chimiddleware.GetReqID(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+			if tc.incoming != "" {
+				req.Header.Set(RequestIDHeader, tc.incoming)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			// Assert the same ID reaches handlers and the response
+			if seen == "" || w.Header().Get(RequestIDHeader) != seen {
+				t.Errorf("Expected matching IDs, got %q in context and %q in response", seen, w.Header().Get(RequestIDHeader))
+			}
+			if (seen == tc.incoming) != tc.keep {
+				t.Errorf("Expected incoming ID kept=%v, got %q", tc.keep, seen)
+			}
+		})
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	// Create a router that authenticates and logs requests
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	verifier := &mockAuthVerifier{
+		verifyFunc: func(ctx context.Context, idToken string) (*auth.Token, error) {
+			return &auth.Token{UID: "u1"}, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	r.Use(AccessLog(logger))
+	r.With(authMiddlewareWithVerifier(verifier)).Get("/api/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/users/42?token=secret", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode log line %q: %v", buf.String(), err)
+	}
+
+	// Assert the line describes the request without leaking credentials
+	if record["route"] != "/api/users/{id}" {
+		t.Errorf("Expected route pattern, got %v", record["route"])
+	}
+	if record["status"] != float64(http.StatusTeapot) {
+		t.Errorf("Expected status %d, got %v", http.StatusTeapot, record["status"])
+	}
+	if record["uid"] != "u1" {
+		t.Errorf("Expected uid u1, got %v", record["uid"])
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("Expected no credentials in %s", buf.String())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strings"
+	"time"
 
 	"firebase.google.com/go/v4/auth"
 )
@@ -18,23 +19,89 @@ type AuthVerifier interface {
 
 // firebaseAuthClient wraps the Firebase auth.Client to implement AuthVerifier
 type firebaseAuthClient struct {
-	client *auth.Client
+	client       *auth.Client
+	checkRevoked bool
 }
 
 func (f *firebaseAuthClient) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	if f.checkRevoked {
+		return f.client.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+	}
 	return f.client.VerifyIDToken(ctx, idToken)
 }
 
-func AuthMiddleware(client *auth.Client) func(handler http.Handler) http.Handler {
-	return authMiddlewareWithVerifier(&firebaseAuthClient{client: client})
+// authConfig holds the settings applied by AuthOptions
+type authConfig struct {
+	checkRevoked bool
+	apiTokens    APITokenVerifier
+	cache        *TokenCache
+	demoUID      string
+}
+
+// AuthOption configures AuthMiddleware
+type AuthOption func(*authConfig)
+
+// WithRevocationCheck makes every request also check with Firebase that the
+// token has not been revoked and the user is not disabled. This costs a call
+// to Firebase per request.
+func WithRevocationCheck(enabled bool) AuthOption {
+	return func(c *authConfig) {
+		c.checkRevoked = enabled
+	}
+}
+
+func AuthMiddleware(client *auth.Client, opts ...AuthOption) func(handler http.Handler) http.Handler {
+	cfg := newAuthConfig(opts)
+	return cfg.middleware(&firebaseAuthClient{client: client, checkRevoked: cfg.checkRevoked})
+}
+
+// VerifierMiddleware is AuthMiddleware for ID tokens checked by verifier
+// rather than Firebase. WithRevocationCheck has no effect.
+func VerifierMiddleware(verifier AuthVerifier, opts ...AuthOption) func(handler http.Handler) http.Handler {
+	return newAuthConfig(opts).middleware(verifier)
+}
+
+// cacheMaxAge is the cache TTL, capped when revocation must be checked
+func (c authConfig) cacheMaxAge() time.Duration {
+	if c.checkRevoked && c.cache.ttl > RevocationCacheTTL {
+		return RevocationCacheTTL
+	}
+	return c.cache.ttl
+}
+
+func newAuthConfig(opts []AuthOption) authConfig {
+	var cfg authConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+func (c authConfig) middleware(verifier AuthVerifier) func(handler http.Handler) http.Handler {
+	if c.cache != nil {
+		verifier = &cachingVerifier{next: verifier, cache: c.cache, maxAge: c.cacheMaxAge()}
+	}
+	// Personal access tokens bypass the cache so revoking one takes effect at once
+	if c.apiTokens != nil {
+		verifier = &apiTokenAwareVerifier{idTokens: verifier, apiTokens: c.apiTokens}
+	}
+	verifier = &tracingVerifier{next: verifier}
+	if c.demoUID != "" {
+		return withDemo(c.demoUID, authMiddlewareWithVerifier(verifier))
+	}
+	return authMiddlewareWithVerifier(verifier)
 }
 
 func authMiddlewareWithVerifier(verifier AuthVerifier) func(handler http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				writeAuthFailure(w, r, ReasonMissing)
+				return
+			}
 			if !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				writeAuthFailure(w, r, ReasonMalformed)
 				return
 			}
 
@@ -42,10 +109,11 @@ func authMiddlewareWithVerifier(verifier AuthVerifier) func(handler http.Handler
 
 			token, err := verifier.VerifyIDToken(r.Context(), jwt)
 			if err != nil {
-				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				writeAuthFailure(w, r, ClassifyAuthError(err))
 				return
 			}
 
+			setAccessLogUID(r.Context(), token.UID)
 			ctx := context.WithValue(r.Context(), CtxTokenKey{}, token)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
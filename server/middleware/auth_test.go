@@ -3,11 +3,15 @@ package middleware
 import (
 	"context"
 	"errors"
+	"fif/metrics"
+	"fif/problem"
+	"fif/problem/problemtest"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"firebase.google.com/go/v4/auth"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 // mockAuthVerifier is a mock implementation of the AuthVerifier interface for testing
@@ -50,10 +54,7 @@ func TestAuthMiddleware_MissingAuthorizationHeader(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
 
-	expectedBody := "unauthorized\n"
-	if w.Body.String() != expectedBody {
-		t.Errorf("Expected body %q, got %q", expectedBody, w.Body.String())
-	}
+	problemtest.Assert(t, w, problem.CodeUnauthorized)
 }
 
 func TestAuthMiddleware_InvalidAuthorizationHeaderFormat(t *testing.T) {
@@ -148,10 +149,7 @@ func TestAuthMiddleware_TokenVerificationFailure(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusUnauthorized, w.Code)
 	}
 
-	expectedBody := "unauthorized\n"
-	if w.Body.String() != expectedBody {
-		t.Errorf("Expected body %q, got %q", expectedBody, w.Body.String())
-	}
+	problemtest.Assert(t, w, problem.CodeTokenInvalid)
 }
 
 func TestAuthMiddleware_SuccessfulAuthentication(t *testing.T) {
@@ -343,3 +341,115 @@ func TestAuthMiddleware_MultipleRequests(t *testing.T) {
 		t.Errorf("Expected verifier to be called 3 times, got %d", callCount)
 	}
 }
+
+func TestAuthMiddleware_ClassifiesFailures(t *testing.T) {
+	testCases := []struct {
+		name              string
+		authHeader        string
+		verifyErr         error
+		expectedStatus    int
+		expectedCode      string
+		expectedReason    string
+		expectedChallenge string
+	}{
+		{
+			name:              "Missing",
+			authHeader:        "",
+			expectedStatus:    http.StatusUnauthorized,
+			expectedCode:      problem.CodeUnauthorized,
+			expectedReason:    ReasonMissing,
+			expectedChallenge: `Bearer realm="fif"`,
+		},
+		{
+			name:              "WrongScheme",
+			authHeader:        "Basic dXNlcjpwYXNz",
+			expectedStatus:    http.StatusUnauthorized,
+			expectedCode:      problem.CodeTokenInvalid,
+			expectedReason:    ReasonMalformed,
+			expectedChallenge: `Bearer realm="fif", error="invalid_token", error_description="the token is malformed"`,
+		},
+		{
+			name:              "Expired",
+			authHeader:        "Bearer token",
+			verifyErr:         &AuthError{Reason: ReasonExpired, Err: errors.New("exp in the past")},
+			expectedStatus:    http.StatusUnauthorized,
+			expectedCode:      problem.CodeTokenExpired,
+			expectedReason:    ReasonExpired,
+			expectedChallenge: `Bearer realm="fif", error="invalid_token", error_description="the token has expired"`,
+		},
+		{
+			name:              "Revoked",
+			authHeader:        "Bearer token",
+			verifyErr:         &AuthError{Reason: ReasonRevoked, Err: errors.New("revoked")},
+			expectedStatus:    http.StatusUnauthorized,
+			expectedCode:      problem.CodeTokenRevoked,
+			expectedReason:    ReasonRevoked,
+			expectedChallenge: `Bearer realm="fif", error="invalid_token", error_description="the token has been revoked"`,
+		},
+		{
+			name:              "WrongAudience",
+			authHeader:        "Bearer token",
+			verifyErr:         errors.New(`ID token has invalid 'aud' (audience) claim; expected "a" but got "b"`),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedCode:      problem.CodeTokenInvalid,
+			expectedReason:    ReasonWrongAudience,
+			expectedChallenge: `Bearer realm="fif", error="invalid_token", error_description="the token was issued for a different audience"`,
+		},
+		{
+			name:              "Malformed",
+			authHeader:        "Bearer token",
+			verifyErr:         errors.New("incorrect number of segments"),
+			expectedStatus:    http.StatusUnauthorized,
+			expectedCode:      problem.CodeTokenInvalid,
+			expectedReason:    ReasonMalformed,
+			expectedChallenge: `Bearer realm="fif", error="invalid_token", error_description="the token is malformed"`,
+		},
+		{
+			name:           "Unavailable",
+			authHeader:     "Bearer token",
+			verifyErr:      &AuthError{Reason: ReasonUnavailable, Err: errors.New("certificate fetch failed")},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   problem.CodeAuthUnavailable,
+			expectedReason: ReasonUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockVerifier := &mockAuthVerifier{
+				verifyFunc: func(ctx context.Context, idToken string) (*auth.Token, error) {
+					return nil, tc.verifyErr
+				},
+			}
+			handler := authMiddlewareWithVerifier(mockVerifier)(mockHandler())
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			if tc.authHeader != "" {
+				req.Header.Set("Authorization", tc.authHeader)
+			}
+			w := httptest.NewRecorder()
+
+			before := failureCount(tc.expectedReason)
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if challenge := w.Header().Get("WWW-Authenticate"); challenge != tc.expectedChallenge {
+				t.Errorf("Expected WWW-Authenticate %q, got %q", tc.expectedChallenge, challenge)
+			}
+
+			if after := failureCount(tc.expectedReason); after != before+1 {
+				t.Errorf("Expected %s failure count to increase by 1, went from %d to %d", tc.expectedReason, before, after)
+			}
+
+			problemtest.Assert(t, w, tc.expectedCode)
+		})
+	}
+}
+
+// failureCount reads the auth failures counter for a reason
+func failureCount(reason string) int64 {
+	return int64(testutil.ToFloat64(metrics.AuthFailures.WithLabelValues(reason)))
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"errors"
+	"fif/metrics"
+	"fif/problem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// Reasons an authentication attempt can fail
+const (
+	ReasonMissing       = "missing"
+	ReasonMalformed     = "malformed"
+	ReasonExpired       = "expired"
+	ReasonRevoked       = "revoked"
+	ReasonDisabled      = "disabled"
+	ReasonWrongAudience = "wrong_audience"
+	ReasonWrongIssuer   = "wrong_issuer"
+	ReasonInvalid       = "invalid"
+	ReasonUnavailable   = "unavailable"
+)
+
+// AuthError is a verification failure with a known reason. Verifiers other
+// than Firebase return it so the middleware can classify their failures.
+type AuthError struct {
+	Reason string
+	Err    error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyAuthError maps a verification error to one of the Reason constants
+func ClassifyAuthError(err error) string {
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return authErr.Reason
+	}
+
+	switch {
+	case auth.IsIDTokenExpired(err):
+		return ReasonExpired
+	case auth.IsIDTokenRevoked(err):
+		return ReasonRevoked
+	case auth.IsUserDisabled(err):
+		return ReasonDisabled
+	case auth.IsCertificateFetchFailed(err):
+		return ReasonUnavailable
+	}
+
+	// The Firebase SDK reports every other problem as ID_TOKEN_INVALID, so
+	// fall back to its messages to tell them apart
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "invalid 'aud'"):
+		return ReasonWrongAudience
+	case strings.Contains(msg, "invalid 'iss'"):
+		return ReasonWrongIssuer
+	case strings.Contains(msg, "incorrect number of segments"),
+		strings.Contains(msg, "must be a non-empty string"),
+		strings.Contains(msg, "illegal base64"),
+		strings.Contains(msg, "invalid character"):
+		return ReasonMalformed
+	}
+	return ReasonInvalid
+}
+
+// writeAuthFailure counts the failure and sends a problem response with an
+// RFC 6750 WWW-Authenticate challenge
+func writeAuthFailure(w http.ResponseWriter, r *http.Request, reason string) {
+	metrics.AuthFailures.WithLabelValues(reason).Inc()
+
+	status, code, bearerError, detail := http.StatusUnauthorized, problem.CodeTokenInvalid, "invalid_token", ""
+	switch reason {
+	case ReasonMissing:
+		code, bearerError, detail = problem.CodeUnauthorized, "", "authentication is required"
+	case ReasonMalformed:
+		detail = "the token is malformed"
+	case ReasonExpired:
+		code, detail = problem.CodeTokenExpired, "the token has expired"
+	case ReasonRevoked:
+		code, detail = problem.CodeTokenRevoked, "the token has been revoked"
+	case ReasonDisabled:
+		code, detail = problem.CodeTokenRevoked, "the account has been disabled"
+	case ReasonWrongAudience:
+		detail = "the token was issued for a different audience"
+	case ReasonWrongIssuer:
+		detail = "the token was issued by an untrusted issuer"
+	case ReasonUnavailable:
+		status, code, bearerError, detail = http.StatusServiceUnavailable, problem.CodeAuthUnavailable, "", "tokens cannot be verified right now"
+	default:
+		detail = "the token could not be verified"
+	}
+
+	if status == http.StatusUnauthorized {
+		challenge := `Bearer realm="fif"`
+		if bearerError != "" {
+			challenge += fmt.Sprintf(`, error="%s", error_description="%s"`, bearerError, detail)
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+
+	problem.Write(w, r, status, code, detail)
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// ClaimDemo is set on the token given to visitors in demo mode
+const ClaimDemo = "demo"
+
+// WithDemoUser lets requests without an Authorization header through as uid,
+// the owner of the demo portfolio. Demo sessions may read but not change
+// anything: they carry every read scope and are refused by RequireSession and
+// RequireRole.
+func WithDemoUser(uid string) AuthOption {
+	return func(c *authConfig) {
+		c.demoUID = uid
+	}
+}
+
+// IsDemo reports whether a token is a demo session
+func IsDemo(token *auth.Token) bool {
+	demo, _ := token.Claims[ClaimDemo].(bool)
+	return demo
+}
+
+// demoToken is the token for an anonymous demo visitor
+func demoToken(uid string) *auth.Token {
+	return &auth.Token{
+		UID: uid,
+		Claims: map[string]interface{}{
+			ClaimDemo:   true,
+			ClaimScopes: KnownScopes,
+		},
+	}
+}
+
+// withDemo serves requests without credentials as the demo user and passes
+// the rest to authenticated
+func withDemo(uid string, authenticated func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		checked := authenticated(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" {
+				checked.ServeHTTP(w, r)
+				return
+			}
+
+			setAccessLogUID(r.Context(), uid)
+			ctx := context.WithValue(r.Context(), CtxTokenKey{}, demoToken(uid))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
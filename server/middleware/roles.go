@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"fif/problem"
+	"fmt"
+	"net/http"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/lib/pq"
+)
+
+// RoleAdmin grants access to the /api/admin tree
+const RoleAdmin = "admin"
+
+// KnownRoles lists every role that may be assigned to a user
+var KnownRoles = []string{RoleAdmin}
+
+// RoleStore looks up roles assigned to a user outside of their token
+type RoleStore interface {
+	UserRoles(ctx context.Context, uid string) ([]string, error)
+}
+
+// dbRoleStore reads roles from the users table
+type dbRoleStore struct {
+	db *sql.DB
+}
+
+// NewDBRoleStore creates a RoleStore backed by the users table
+func NewDBRoleStore(db *sql.DB) RoleStore {
+	return &dbRoleStore{db: db}
+}
+
+func (s *dbRoleStore) UserRoles(ctx context.Context, uid string) ([]string, error) {
+	var roles []string
+	err := s.db.QueryRowContext(ctx, `SELECT roles FROM users WHERE id = $1`, uid).Scan(pq.Array(&roles))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query roles: %w", err)
+	}
+	return roles, nil
+}
+
+// TokenRoles returns the roles carried in a token's custom claims. Both a
+// "roles" array and the Firebase convention of a boolean claim per role
+// (e.g. {"admin": true}) are accepted.
+func TokenRoles(token *auth.Token) []string {
+	var roles []string
+	if list, ok := token.Claims["roles"].([]interface{}); ok {
+		for _, v := range list {
+			if role, ok := v.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+	for _, role := range KnownRoles {
+		if flag, ok := token.Claims[role].(bool); ok && flag {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// RequireRole allows the request only if the user has role, either in their
+// token's custom claims or, when store is not nil, in the users table. Personal
+// access tokens and demo sessions never carry roles. It must run after
+// AuthMiddleware so the token is in the context.
+func RequireRole(store RoleStore, role string) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(CtxTokenKey{}).(*auth.Token)
+			if !ok || token == nil {
+				problem.Unauthorized(w, r)
+				return
+			}
+
+			if rejectRestricted(w, r, token) {
+				return
+			}
+
+			if contains(TokenRoles(token), role) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if store != nil {
+				roles, err := store.UserRoles(r.Context(), token.UID)
+				if err != nil {
+					problem.Internal(w, r, "Error looking up roles", err)
+					return
+				}
+				if contains(roles, role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden, fmt.Sprintf("the %s role is required", role))
+		})
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
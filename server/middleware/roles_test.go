@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fif/problem"
+	"fif/problem/problemtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// mockRoleStore is a mock implementation of the RoleStore interface for testing
+type mockRoleStore struct {
+	roles map[string][]string
+	err   error
+}
+
+func (m *mockRoleStore) UserRoles(ctx context.Context, uid string) ([]string, error) {
+	return m.roles[uid], m.err
+}
+
+func TestRequireRole(t *testing.T) {
+	testCases := []struct {
+		name           string
+		token          *auth.Token
+		store          RoleStore
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "RolesClaim",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{"roles": []interface{}{"admin"}}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "BooleanClaim",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{"admin": true}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "FalseBooleanClaim",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{"admin": false}},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   problem.CodeForbidden,
+		},
+		{
+			name:           "UsersTable",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{}},
+			store:          &mockRoleStore{roles: map[string][]string{"u1": {"admin"}}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "NoRole",
+			token:          &auth.Token{UID: "u2", Claims: map[string]interface{}{}},
+			store:          &mockRoleStore{roles: map[string][]string{"u1": {"admin"}}},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   problem.CodeForbidden,
+		},
+		{
+			name:           "StoreError",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{}},
+			store:          &mockRoleStore{err: errors.New("db down")},
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   problem.CodeInternal,
+		},
+		{
+			name:           "APITokenOfAdmin",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{ClaimAPITokenID: "t1", "admin": true}},
+			store:          &mockRoleStore{roles: map[string][]string{"u1": {"admin"}}},
+			expectedStatus: http.StatusForbidden,
+			expectedCode:   problem.CodeForbidden,
+		},
+		{
+			name:           "NoToken",
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   problem.CodeUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := RequireRole(tc.store, RoleAdmin)(mockHandler())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/admin/users", nil)
+			if tc.token != nil {
+				req = req.WithContext(context.WithValue(req.Context(), CtxTokenKey{}, tc.token))
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+
+			if tc.expectedCode != "" {
+				problemtest.Assert(t, w, tc.expectedCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"context"
+	"fif/problem"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// Scopes that personal access tokens can be issued with
+const (
+	ScopeHoldingsRead  = "holdings:read"
+	ScopePortfolioRead = "portfolio:read"
+	ScopeAccountRead   = "account:read"
+)
+
+// KnownScopes lists every scope a personal access token may carry
+var KnownScopes = []string{ScopeHoldingsRead, ScopePortfolioRead, ScopeAccountRead}
+
+// Claims set on the synthesized token for requests made with a personal access token
+const (
+	ClaimAPITokenID = "api_token_id"
+	ClaimScopes     = "scopes"
+)
+
+// APITokenPrefix starts every personal access token, which is how they are
+// told apart from ID tokens
+const APITokenPrefix = "fif_pat_"
+
+// APITokenVerifier checks a personal access token and returns a token for its
+// owner carrying ClaimAPITokenID and ClaimScopes. Failures should be returned
+// as *AuthError so they are classified.
+type APITokenVerifier interface {
+	VerifyAPIToken(ctx context.Context, raw string) (*auth.Token, error)
+}
+
+// apiTokenAwareVerifier sends personal access tokens to one verifier and
+// everything else to the ID token verifier
+type apiTokenAwareVerifier struct {
+	idTokens  AuthVerifier
+	apiTokens APITokenVerifier
+}
+
+func (v *apiTokenAwareVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	if strings.HasPrefix(idToken, APITokenPrefix) {
+		return v.apiTokens.VerifyAPIToken(ctx, idToken)
+	}
+	return v.idTokens.VerifyIDToken(ctx, idToken)
+}
+
+// WithAPITokens also accepts personal access tokens, checked by verifier
+func WithAPITokens(verifier APITokenVerifier) AuthOption {
+	return func(c *authConfig) {
+		c.apiTokens = verifier
+	}
+}
+
+// IsAPIToken reports whether a token came from a personal access token
+func IsAPIToken(token *auth.Token) bool {
+	_, ok := token.Claims[ClaimAPITokenID]
+	return ok
+}
+
+// TokenScopes returns the scopes of a personal access token
+func TokenScopes(token *auth.Token) []string {
+	var scopes []string
+	switch list := token.Claims[ClaimScopes].(type) {
+	case []string:
+		scopes = list
+	case []interface{}:
+		for _, v := range list {
+			if scope, ok := v.(string); ok {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// RequireScope lets ID tokens through and requires personal access tokens and
+// demo sessions to carry scope. It must run after AuthMiddleware.
+func RequireScope(scope string) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(CtxTokenKey{}).(*auth.Token)
+			if !ok || token == nil {
+				problem.Unauthorized(w, r)
+				return
+			}
+
+			if (IsAPIToken(token) || IsDemo(token)) && !contains(TokenScopes(token), scope) {
+				problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden,
+					fmt.Sprintf("the token does not have the %s scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects personal access tokens and demo sessions, for routes
+// that manage the account or change data. It must run after AuthMiddleware.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := r.Context().Value(CtxTokenKey{}).(*auth.Token)
+		if !ok || token == nil {
+			problem.Unauthorized(w, r)
+			return
+		}
+
+		if rejectRestricted(w, r, token) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectRestricted answers 403 and returns true for personal access tokens
+// and demo sessions
+func rejectRestricted(w http.ResponseWriter, r *http.Request, token *auth.Token) bool {
+	switch {
+	case IsAPIToken(token):
+		problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden,
+			"this endpoint cannot be used with a personal access token")
+		return true
+	case IsDemo(token):
+		problem.Write(w, r, http.StatusForbidden, problem.CodeForbidden,
+			"the demo is read-only; sign in to make changes")
+		return true
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"fif/problem"
+	"fif/problem/problemtest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// mockAPITokenVerifier is a mock implementation of the APITokenVerifier interface for testing
+type mockAPITokenVerifier struct {
+	scopes []string
+	err    error
+}
+
+func (m *mockAPITokenVerifier) VerifyAPIToken(ctx context.Context, raw string) (*auth.Token, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &auth.Token{UID: "owner", Claims: map[string]interface{}{ClaimAPITokenID: "t1", ClaimScopes: m.scopes}}, nil
+}
+
+func TestAuthMiddleware_RoutesAPITokens(t *testing.T) {
+	testCases := []struct {
+		name           string
+		bearer         string
+		apiTokens      *mockAPITokenVerifier
+		expectedStatus int
+		expectedCode   string
+		expectedUID    string
+	}{
+		{
+			name:           "IDToken",
+			bearer:         "id-token",
+			apiTokens:      &mockAPITokenVerifier{},
+			expectedStatus: http.StatusOK,
+			expectedUID:    "firebase-user",
+		},
+		{
+			name:           "APIToken",
+			bearer:         APITokenPrefix + "secret",
+			apiTokens:      &mockAPITokenVerifier{scopes: []string{ScopeHoldingsRead}},
+			expectedStatus: http.StatusOK,
+			expectedUID:    "owner",
+		},
+		{
+			name:           "RevokedAPIToken",
+			bearer:         APITokenPrefix + "secret",
+			apiTokens:      &mockAPITokenVerifier{err: &AuthError{Reason: ReasonRevoked}},
+			expectedStatus: http.StatusUnauthorized,
+			expectedCode:   problem.CodeTokenRevoked,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a verifier that only knows ID tokens, wrapped to accept API tokens
+			idTokens := &mockAuthVerifier{
+				verifyFunc: func(ctx context.Context, idToken string) (*auth.Token, error) {
+					return &auth.Token{UID: "firebase-user"}, nil
+				},
+			}
+			verifier := &apiTokenAwareVerifier{idTokens: idTokens, apiTokens: tc.apiTokens}
+
+			var uid string
+			handler := authMiddlewareWithVerifier(verifier)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				uid = r.Context().Value(CtxTokenKey{}).(*auth.Token).UID
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/holdings", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			// Assert the request reached the right verifier
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if uid != tc.expectedUID {
+				t.Errorf("Expected UID %q, got %q", tc.expectedUID, uid)
+			}
+			if tc.expectedCode != "" {
+				problemtest.Assert(t, w, tc.expectedCode)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	testCases := []struct {
+		name           string
+		token          *auth.Token
+		expectedStatus int
+	}{
+		{
+			name:           "IDToken",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "APITokenWithScope",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{ClaimAPITokenID: "t1", ClaimScopes: []string{ScopePortfolioRead}}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "APITokenWithoutScope",
+			token:          &auth.Token{UID: "u1", Claims: map[string]interface{}{ClaimAPITokenID: "t1", ClaimScopes: []string{ScopeHoldingsRead}}},
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "NoToken",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := RequireScope(ScopePortfolioRead)(mockHandler())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/portfolio/valuation", nil)
+			if tc.token != nil {
+				req = req.WithContext(context.WithValue(req.Context(), CtxTokenKey{}, tc.token))
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	// Create a request made with an API token that has every scope
+	token := &auth.Token{UID: "u1", Claims: map[string]interface{}{ClaimAPITokenID: "t1", ClaimScopes: KnownScopes}}
+	req := httptest.NewRequest(http.MethodPost, "/api/account/tokens", nil)
+	req = req.WithContext(context.WithValue(req.Context(), CtxTokenKey{}, token))
+	w := httptest.NewRecorder()
+
+	RequireSession(mockHandler()).ServeHTTP(w, req)
+
+	// Assert API tokens cannot manage the account
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status %d, got %d", http.StatusForbidden, w.Code)
+	}
+
+	problemtest.Assert(t, w, problem.CodeForbidden)
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCSP is a policy with a nonce, like the default one built by config
+const testCSP = "default-src 'self'; script-src 'self' 'nonce-" + NonceToken + "'"
+
+func TestSecurityHeaders(t *testing.T) {
+	// Create a handler behind a policy with a nonce
+	var nonce string
+	handler := SecurityHeaders(SecurityOptions{
+		HSTSMaxAge:     365 * 24 * time.Hour,
+		ReferrerPolicy: "strict-origin-when-cross-origin",
+		FrameAncestors: "'none'",
+		CSP:            testCSP,
+		CSPReportURI:   "/api/csp-report",
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = CSPNonce(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Assert the fixed headers
+	expected := map[string]string{
+		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":    "nosniff",
+		"Referrer-Policy":           "strict-origin-when-cross-origin",
+		"X-Frame-Options":           "DENY",
+		"Reporting-Endpoints":       `csp="/api/csp-report"`,
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+
+	// Assert the policy carries this request's nonce, framing and reporting
+	csp := w.Header().Get("Content-Security-Policy")
+	if nonce == "" || !strings.Contains(csp, "'nonce-"+nonce+"'") {
+		t.Errorf("Expected policy with nonce %q, got %q", nonce, csp)
+	}
+	for _, directive := range []string{"frame-ancestors 'none'", "report-uri /api/csp-report", "report-to csp"} {
+		if !strings.Contains(csp, directive) {
+			t.Errorf("Expected policy to contain %q, got %q", directive, csp)
+		}
+	}
+
+	// Assert the next request gets a different nonce
+	first := nonce
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if nonce == first {
+		t.Error("Expected a fresh nonce per request")
+	}
+}
+
+func TestSecurityHeaders_ReportOnly(t *testing.T) {
+	handler := SecurityHeaders(SecurityOptions{
+		CSP:           "default-src 'self';",
+		CSPReportOnly: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// Assert the policy is only reported, and HSTS is off without a max age
+	if got := w.Header().Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'" {
+		t.Errorf("Expected report-only policy, got %q", got)
+	}
+	if got := w.Header().Get("Content-Security-Policy"); got != "" {
+		t.Errorf("Expected no enforced policy, got %q", got)
+	}
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Expected no HSTS, got %q", got)
+	}
+}
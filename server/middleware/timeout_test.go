@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	testCases := []struct {
+		name        string
+		timeout     time.Duration
+		hasDeadline bool
+	}{
+		{name: "SetsDeadline", timeout: time.Second, hasDeadline: true},
+		{name: "ZeroDisables", timeout: 0, hasDeadline: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var deadline time.Time
+			var ok bool
+			handler := Timeout(tc.timeout)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, ok = r.Context().Deadline()
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/holdings", nil))
+			latest := time.Now().Add(tc.timeout)
+
+			// Assert the handler saw the expected deadline
+			if ok != tc.hasDeadline {
+				t.Fatalf("Expected deadline %v, got %v", tc.hasDeadline, ok)
+			}
+			if ok && deadline.After(latest) {
+				t.Errorf("Expected deadline within %s, got %s", tc.timeout, time.Until(deadline))
+			}
+		})
+	}
+}
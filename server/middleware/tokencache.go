@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fif/metrics"
+	"strings"
+	"sync"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RevocationCacheTTL caps how long a verified token is trusted without asking
+// Firebase again when revocation checking is enabled
+const RevocationCacheTTL = 30 * time.Second
+
+// TokenCache is a bounded LRU of verified ID tokens keyed by a hash of the
+// raw token. It is safe for concurrent use.
+type TokenCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // front is most recently used
+	now     func() time.Time
+}
+
+type tokenCacheEntry struct {
+	key       [sha256.Size]byte
+	token     *auth.Token
+	expiresAt time.Time
+}
+
+// NewTokenCache creates a cache holding at most size tokens, each for no
+// longer than ttl or the token's own expiry, whichever is sooner
+func NewTokenCache(size int, ttl time.Duration) *TokenCache {
+	return &TokenCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[[sha256.Size]byte]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the cached token for raw if it has not expired
+func (c *TokenCache) get(raw string) (*auth.Token, bool) {
+	key := sha256.Sum256([]byte(raw))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		metrics.AuthTokenCache.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	entry := el.Value.(*tokenCacheEntry)
+	if !c.now().Before(entry.expiresAt) {
+		c.remove(el)
+		metrics.AuthTokenCache.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+
+	c.order.MoveToFront(el)
+	metrics.AuthTokenCache.WithLabelValues("hit").Inc()
+	return entry.token, true
+}
+
+// put caches token for at most maxAge
+func (c *TokenCache) put(raw string, token *auth.Token, maxAge time.Duration) {
+	now := c.now()
+	expiresAt := now.Add(maxAge)
+	if exp := time.Unix(token.Expires, 0); token.Expires != 0 && exp.Before(expiresAt) {
+		expiresAt = exp
+	}
+	if !now.Before(expiresAt) || c.size <= 0 {
+		return
+	}
+
+	key := sha256.Sum256([]byte(raw))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.order.PushFront(&tokenCacheEntry{key: key, token: token, expiresAt: expiresAt})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		metrics.AuthTokenCacheEvictions.Inc()
+	}
+}
+
+// InvalidateUser drops every cached token belonging to uid
+func (c *TokenCache) InvalidateUser(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*tokenCacheEntry).token.UID == uid {
+			c.remove(el)
+		}
+		el = next
+	}
+}
+
+// Len returns the number of cached tokens, including expired ones not yet removed
+func (c *TokenCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// remove must be called with mu held
+func (c *TokenCache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*tokenCacheEntry).key)
+}
+
+// WithTokenCache reuses verified ID tokens from cache instead of verifying
+// them on every request. With revocation checking enabled tokens are cached
+// for at most RevocationCacheTTL.
+func WithTokenCache(cache *TokenCache) AuthOption {
+	return func(c *authConfig) {
+		c.cache = cache
+	}
+}
+
+// cachingVerifier answers from the cache and falls back to the wrapped verifier
+type cachingVerifier struct {
+	next   AuthVerifier
+	cache  *TokenCache
+	maxAge time.Duration
+}
+
+func (v *cachingVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	if token, ok := v.cache.get(idToken); ok {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("auth.cache_hit", true))
+		return token, nil
+	}
+
+	token, err := v.next.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		// A revoked or disabled user's other tokens may still be cached.
+		// Dropping entries is harmless, so the unverified subject will do.
+		if reason := ClassifyAuthError(err); reason == ReasonRevoked || reason == ReasonDisabled {
+			if uid := unverifiedSubject(idToken); uid != "" {
+				v.cache.InvalidateUser(uid)
+			}
+		}
+		return nil, err
+	}
+
+	v.cache.put(idToken, token, v.maxAge)
+	return token, nil
+}
+
+// unverifiedSubject reads the sub claim of a JWT without checking its signature
+func unverifiedSubject(raw string) string {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Sub
+}
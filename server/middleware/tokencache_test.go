@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// countingVerifier returns a token for any input and counts verifications
+type countingVerifier struct {
+	mu      sync.Mutex
+	calls   int
+	expires time.Time
+	err     error
+}
+
+func (v *countingVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.calls++
+	if v.err != nil {
+		return nil, v.err
+	}
+	return &auth.Token{UID: "u-" + idToken, Expires: v.expires.Unix()}, nil
+}
+
+// fakeJWT builds an unsigned token whose payload names sub
+func fakeJWT(sub string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"` + sub + `"}`))
+	return "e30." + payload + ".sig"
+}
+
+func TestCachingVerifier_ReusesTokens(t *testing.T) {
+	// Create a cache in front of a verifier
+	next := &countingVerifier{expires: time.Now().Add(time.Hour)}
+	cache := NewTokenCache(10, time.Minute)
+	v := &cachingVerifier{next: next, cache: cache, maxAge: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		if _, err := v.VerifyIDToken(context.Background(), "a"); err != nil {
+			t.Fatalf("VerifyIDToken failed: %v", err)
+		}
+	}
+
+	// Assert the token was only verified once
+	if next.calls != 1 {
+		t.Errorf("Expected 1 verification, got %d", next.calls)
+	}
+}
+
+func TestTokenCache_HonoursExpiry(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		name      string
+		expires   time.Time
+		maxAge    time.Duration
+		advance   time.Duration
+		expectHit bool
+	}{
+		{name: "WithinBoth", expires: now.Add(time.Hour), maxAge: time.Minute, advance: 30 * time.Second, expectHit: true},
+		{name: "PastCeiling", expires: now.Add(time.Hour), maxAge: time.Minute, advance: 2 * time.Minute, expectHit: false},
+		{name: "PastTokenExpiry", expires: now.Add(10 * time.Second), maxAge: time.Minute, advance: 20 * time.Second, expectHit: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Create a cache with a controllable clock
+			clock := now
+			cache := NewTokenCache(10, tc.maxAge)
+			cache.now = func() time.Time { return clock }
+
+			cache.put("a", &auth.Token{UID: "u1", Expires: tc.expires.Unix()}, tc.maxAge)
+			clock = clock.Add(tc.advance)
+
+			// Assert the entry is only served while both limits hold
+			if _, ok := cache.get("a"); ok != tc.expectHit {
+				t.Errorf("Expected hit %v, got %v", tc.expectHit, ok)
+			}
+		})
+	}
+}
+
+func TestTokenCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	// Create a cache with room for two tokens
+	cache := NewTokenCache(2, time.Minute)
+	token := func(uid string) *auth.Token {
+		return &auth.Token{UID: uid, Expires: time.Now().Add(time.Hour).Unix()}
+	}
+
+	cache.put("a", token("a"), time.Minute)
+	cache.put("b", token("b"), time.Minute)
+	cache.get("a")
+	cache.put("c", token("c"), time.Minute)
+
+	// Assert b, the least recently used, was evicted
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Error("Expected a to be kept")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestCachingVerifier_InvalidatesRevokedUsers(t *testing.T) {
+	// Create a cache holding a token for u-alice
+	next := &countingVerifier{expires: time.Now().Add(time.Hour)}
+	cache := NewTokenCache(10, time.Minute)
+	v := &cachingVerifier{next: next, cache: cache, maxAge: time.Minute}
+	v.VerifyIDToken(context.Background(), "alice")
+
+	// Another of the user's tokens turns out to be revoked
+	next.err = &AuthError{Reason: ReasonRevoked, Err: errors.New("revoked")}
+	if _, err := v.VerifyIDToken(context.Background(), fakeJWT("u-alice")); err == nil {
+		t.Fatal("Expected the revoked token to be rejected")
+	}
+
+	// Assert the cached token was dropped
+	if cache.Len() != 0 {
+		t.Errorf("Expected the user's cached tokens to be dropped, %d left", cache.Len())
+	}
+}
+
+func TestAuthConfig_CacheMaxAge(t *testing.T) {
+	testCases := []struct {
+		name         string
+		checkRevoked bool
+		ttl          time.Duration
+		expected     time.Duration
+	}{
+		{name: "NoRevocationCheck", ttl: time.Hour, expected: time.Hour},
+		{name: "RevocationCheckCaps", checkRevoked: true, ttl: time.Hour, expected: RevocationCacheTTL},
+		{name: "ShortTTLKept", checkRevoked: true, ttl: 10 * time.Second, expected: 10 * time.Second},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := authConfig{checkRevoked: tc.checkRevoked, cache: NewTokenCache(10, tc.ttl)}
+			if got := cfg.cacheMaxAge(); got != tc.expected {
+				t.Errorf("Expected max age %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"fif/tracing"
+	"strings"
+
+	"firebase.google.com/go/v4/auth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingVerifier records a span around token verification so slow Firebase
+// or database lookups show up in traces
+type tracingVerifier struct {
+	next AuthVerifier
+}
+
+func (v *tracingVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	tokenType := "id_token"
+	if strings.HasPrefix(idToken, APITokenPrefix) {
+		tokenType = "api_token"
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "auth.VerifyToken",
+		trace.WithAttributes(attribute.String("auth.token_type", tokenType)))
+	defer span.End()
+
+	token, err := v.next.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		// The reason is enough; errors can quote the token
+		reason := ClassifyAuthError(err)
+		span.SetAttributes(attribute.String("auth.failure_reason", reason))
+		span.SetStatus(codes.Error, reason)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("enduser.id", token.UID))
+	return token, nil
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"firebase.google.com/go/v4/auth"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingVerifier_RecordsFailureReason(t *testing.T) {
+	// Create a provider that records spans in memory
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	v := &tracingVerifier{next: &mockAuthVerifier{
+		verifyFunc: func(ctx context.Context, idToken string) (*auth.Token, error) {
+			return nil, &AuthError{Reason: ReasonExpired, Err: errors.New("token secret-token has expired")}
+		},
+	}}
+	v.VerifyIDToken(context.Background(), "secret-token")
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+
+	// Assert the reason is recorded and the token is not
+	var reason string
+	for _, attr := range spans[0].Attributes() {
+		if attr.Key == "auth.failure_reason" {
+			reason = attr.Value.AsString()
+		}
+		if strings.Contains(attr.Value.Emit(), "secret-token") {
+			t.Errorf("Expected the token to stay out of span attribute %s", attr.Key)
+		}
+	}
+	if reason != ReasonExpired {
+		t.Errorf("Expected failure reason %s, got %q", ReasonExpired, reason)
+	}
+}
@@ -47,12 +47,3 @@ CREATE INDEX IF NOT EXISTS idx_holdings_user_id
 -- Fast "list holdings in order" queries
 CREATE INDEX IF NOT EXISTS idx_holdings_user_id_created_at
     ON holdings(user_id, created_at DESC);
-
--- User UIDs (like Firebase)
--- Replace these with your real Firebase test users if needed
-INSERT INTO holdings (user_id, name, symbol, quantity, currency, cost)
-VALUES
--- ===== User 1 =====
-('v69VFq5fjfhjj4IVckGxL4A1UP92', 'Vanguard Total Stock Market ETF', 'VTI', 12.34567890, 'USD', 2500.00),
-('v69VFq5fjfhjj4IVckGxL4A1UP92', 'Apple Inc.', 'AAPL', 20.00000000, 'USD', 3000.00),
-('v69VFq5fjfhjj4IVckGxL4A1UP92', 'Tesla Inc.', 'TSLA', 5.00000000, 'USD', 1100.00),
\ No newline at end of file
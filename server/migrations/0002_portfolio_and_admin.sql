@@ -0,0 +1,154 @@
+-- =========================================
+-- PRICES TABLE
+-- =========================================
+
+-- Daily closing prices, quoted in the instrument's trading currency
+CREATE TABLE IF NOT EXISTS prices (
+    symbol VARCHAR(16) NOT NULL,
+    price_date DATE NOT NULL,
+    close NUMERIC(20, 8) NOT NULL CHECK (close >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (symbol, price_date)
+);
+
+-- =========================================
+-- FX RATES TABLE
+-- =========================================
+
+-- Units of currency per 1 NZD (the way RBNZ and IRD publish them)
+CREATE TABLE IF NOT EXISTS fx_rates (
+    currency VARCHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    rate_date DATE NOT NULL,
+    rate NUMERIC(20, 8) NOT NULL CHECK (rate > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (currency, rate_date)
+);
+
+-- =========================================
+-- INSTRUMENTS TABLE
+-- =========================================
+
+-- Reference data for instruments, maintained through the admin API
+CREATE TABLE IF NOT EXISTS instruments (
+    symbol VARCHAR(16) PRIMARY KEY,
+    name TEXT NOT NULL,
+    currency VARCHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    exchange TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS trg_update_instruments_updated_at ON instruments;
+CREATE TRIGGER trg_update_instruments_updated_at
+    BEFORE UPDATE ON instruments
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_updated_at_column();
+
+-- =========================================
+-- PORTFOLIO SNAPSHOTS TABLE
+-- =========================================
+
+-- Daily valuation per user and instrument; NULL values mean a price or rate was missing
+CREATE TABLE IF NOT EXISTS portfolio_snapshots (
+    user_id TEXT NOT NULL,                        -- Firebase UID
+    snapshot_date DATE NOT NULL,
+    symbol VARCHAR(16) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    quantity NUMERIC(20, 8) NOT NULL,
+    cost NUMERIC(20, 2) NOT NULL,
+    market_value NUMERIC(20, 2),
+    cost_nzd NUMERIC(20, 2),
+    market_value_nzd NUMERIC(20, 2),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, snapshot_date, symbol, currency)
+);
+
+-- =========================================
+-- USERS TABLE
+-- =========================================
+
+-- Account settings; a row is created the first time a user saves preferences
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY,                          -- Firebase UID
+    email TEXT NOT NULL DEFAULT '',
+    notifications_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    roles TEXT[] NOT NULL DEFAULT '{}',           -- e.g. 'admin'; token custom claims also count
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS trg_update_users_updated_at ON users;
+CREATE TRIGGER trg_update_users_updated_at
+    BEFORE UPDATE ON users
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_updated_at_column();
+
+-- =========================================
+-- NOTIFICATIONS
+-- =========================================
+
+-- Alerts already emailed, so each is sent once per user and period
+CREATE TABLE IF NOT EXISTS notifications_sent (
+    user_id TEXT NOT NULL,
+    alert_key TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, alert_key)
+);
+
+-- =========================================
+-- SCHEDULED JOBS
+-- =========================================
+
+-- One row per job; a replica may run the job only while it holds the lease
+CREATE TABLE IF NOT EXISTS job_leases (
+    name TEXT PRIMARY KEY,
+    holder TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    slot TIMESTAMPTZ NOT NULL                     -- scheduled time of the last run taken
+);
+
+CREATE TABLE IF NOT EXISTS job_runs (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    holder TEXT NOT NULL,
+    trigger TEXT NOT NULL CHECK (trigger IN ('schedule', 'manual')),
+    status TEXT NOT NULL CHECK (status IN ('running', 'succeeded', 'failed')),
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_name_started_at
+    ON job_runs(name, started_at DESC);
+
+-- =========================================
+-- API TOKENS
+-- =========================================
+
+-- Personal access tokens for scripts; only a SHA-256 hash of the secret is kept
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,              -- hex SHA-256 of the full token
+    hint TEXT NOT NULL,                           -- last characters, to tell tokens apart
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+
+-- =========================================
+-- RATE LIMITS
+-- =========================================
+
+-- Token buckets shared by replicas when RATE_LIMIT_STORE=postgres
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,                         -- group and user or client IP
+    tokens DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,21 @@
+-- =========================================
+-- FEATURE FLAGS TABLE
+-- =========================================
+
+-- Overrides of flags from FEATURE_FLAGS_FILE, managed through the admin API.
+-- A row replaces the file's definition of the flag entirely.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,       -- on for everyone
+    percentage INTEGER NOT NULL DEFAULT 0 CHECK (percentage BETWEEN 0 AND 100),
+    users TEXT[] NOT NULL DEFAULT '{}',           -- Firebase UIDs it is always on for
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS trg_update_feature_flags_updated_at ON feature_flags;
+CREATE TRIGGER trg_update_feature_flags_updated_at
+    BEFORE UPDATE ON feature_flags
+    FOR EACH ROW
+    EXECUTE PROCEDURE update_updated_at_column();
@@ -0,0 +1,16 @@
+-- =========================================
+-- IMPORT FAILURES TABLE
+-- =========================================
+
+-- Rows, or whole files, that could not be imported, for admins to review.
+-- line is NULL when the file as a whole failed.
+CREATE TABLE IF NOT EXISTS import_failures (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',             -- file name, or stdin
+    line INTEGER,
+    error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_import_failures_created_at ON import_failures (created_at DESC);
@@ -0,0 +1,48 @@
+-- =========================================
+-- HOLDINGS NZD COST
+-- =========================================
+
+-- The cost in NZD at the exchange rate when it was paid, so NZD gains include
+-- the FX move since and the de minimis test does not drift with the rate.
+-- NULL when no rate was known; valuations then fall back to the current rate.
+ALTER TABLE holdings ADD COLUMN IF NOT EXISTS cost_nzd NUMERIC(20, 2) CHECK (cost_nzd >= 0);
+
+-- Fills cost_nzd from the rate on or before the day the holding was recorded
+-- unless the writer supplied it
+CREATE OR REPLACE FUNCTION set_holding_cost_nzd()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.cost_nzd IS NULL THEN
+        IF NEW.currency = 'NZD' THEN
+            NEW.cost_nzd = NEW.cost;
+        ELSE
+            NEW.cost_nzd = ROUND(NEW.cost / (
+                SELECT rate FROM fx_rates
+                WHERE currency = NEW.currency AND rate_date <= NEW.created_at::date
+                ORDER BY rate_date DESC
+                LIMIT 1
+            ), 2);
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_set_holding_cost_nzd ON holdings;
+CREATE TRIGGER trg_set_holding_cost_nzd
+    BEFORE INSERT ON holdings
+    FOR EACH ROW
+    EXECUTE PROCEDURE set_holding_cost_nzd();
+
+-- Existing holdings get the rate from when they were recorded
+UPDATE holdings h
+SET cost_nzd = CASE
+    WHEN h.currency = 'NZD' THEN h.cost
+    ELSE ROUND(h.cost / (
+        SELECT rate FROM fx_rates r
+        WHERE r.currency = h.currency AND r.rate_date <= h.created_at::date
+        ORDER BY r.rate_date DESC
+        LIMIT 1
+    ), 2)
+END
+WHERE h.cost_nzd IS NULL;
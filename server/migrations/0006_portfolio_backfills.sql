@@ -0,0 +1,21 @@
+-- =========================================
+-- PORTFOLIO BACKFILLS TABLE
+-- =========================================
+
+-- History backfills requested through the API. They can cover years of days,
+-- so the portfolio-backfill job runs them outside the request.
+CREATE TABLE IF NOT EXISTS portfolio_backfills (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL CHECK (to_date >= from_date),
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'succeeded', 'failed')),
+    days INTEGER NOT NULL DEFAULT 0,             -- days snapshotted so far
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_backfills_pending ON portfolio_backfills (id) WHERE status = 'pending';
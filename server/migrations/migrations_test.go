@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAll(t *testing.T) {
+	migrations, err := All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+
+	// Assert versions start at 1 and have no gaps
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected migration %d to have version %d, got %d (%s)", i, i+1, m.Version, m.Name)
+		}
+		if strings.TrimSpace(m.SQL) == "" {
+			t.Errorf("Expected migration %04d to contain SQL", m.Version)
+		}
+	}
+
+	if Latest() != len(migrations) {
+		t.Errorf("Expected Latest %d, got %d", len(migrations), Latest())
+	}
+}
@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"context"
+	"database/sql"
+	"fif/portfolio"
+	"fif/tax"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Notifier evaluates alert rules for every opted-in user and emails new alerts
+type Notifier struct {
+	db     *sql.DB
+	sender Sender
+	now    func() time.Time
+}
+
+// NewNotifier creates a Notifier that sends through sender
+func NewNotifier(db *sql.DB, sender Sender) *Notifier {
+	return &Notifier{db: db, sender: sender, now: time.Now}
+}
+
+type recipient struct {
+	userID string
+	email  string
+}
+
+// Run evaluates and sends alerts for all opted-in users. Each alert is recorded
+// before sending so it goes out at most once; a failed send is forgotten so the
+// next run retries it.
+func (n *Notifier) Run(ctx context.Context) error {
+	recipients, err := n.recipients(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := n.now().UTC()
+	failed := 0
+	for _, rc := range recipients {
+		if err := n.notify(ctx, rc, now); err != nil {
+			slog.Error("Error notifying user", "uid", rc.userID, "error", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d users could not be notified", failed, len(recipients))
+	}
+	return nil
+}
+
+func (n *Notifier) notify(ctx context.Context, rc recipient, now time.Time) error {
+	in, err := n.input(ctx, rc.userID, now)
+	if err != nil {
+		return err
+	}
+
+	for _, alert := range Evaluate(now, in) {
+		claimed, err := n.claim(ctx, rc.userID, alert)
+		if err != nil {
+			return err
+		}
+		if !claimed {
+			continue
+		}
+
+		msg := Message{To: rc.email, Subject: alert.Subject, Body: alert.Body}
+		if err := n.sender.Send(ctx, msg); err != nil {
+			if _, delErr := n.db.ExecContext(ctx, `
+				DELETE FROM notifications_sent WHERE user_id = $1 AND alert_key = $2
+			`, rc.userID, alert.Key); delErr != nil {
+				slog.Error("Error forgetting failed notification", "alert", alert.Key, "error", delErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// input gathers the figures the rules need for one user
+func (n *Notifier) input(ctx context.Context, userID string, now time.Time) (Input, error) {
+	current, err := portfolio.Load(ctx, n.db, userID, now)
+	if err != nil {
+		return Input{}, err
+	}
+
+	in := Input{HasHoldings: len(current.Holdings) > 0}
+	for _, h := range current.Holdings {
+		// NZD holdings are not interests in a foreign investment fund
+		if h.Currency == portfolio.BaseCurrency {
+			continue
+		}
+		if h.CostNZD != nil {
+			in.CostNZD += *h.CostNZD
+		} else {
+			in.CostIncomplete = true
+		}
+	}
+
+	yearEnd, err := portfolio.Load(ctx, n.db, userID, tax.YearEnd(tax.IncomeYear(tax.Date(now))-1))
+	if err != nil {
+		return Input{}, err
+	}
+	for _, h := range yearEnd.Holdings {
+		if h.MissingPrice {
+			in.MissingYearEndPrices = append(in.MissingYearEndPrices, h.Symbol)
+		}
+	}
+
+	return in, nil
+}
+
+// claim records that an alert is being sent, returning false if it already was
+func (n *Notifier) claim(ctx context.Context, userID string, alert Alert) (bool, error) {
+	res, err := n.db.ExecContext(ctx, `
+		INSERT INTO notifications_sent (user_id, alert_key, kind)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, alert_key) DO NOTHING
+	`, userID, alert.Key, alert.Kind)
+	if err != nil {
+		return false, fmt.Errorf("failed to record notification: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record notification: %w", err)
+	}
+	return affected == 1, nil
+}
+
+func (n *Notifier) recipients(ctx context.Context) ([]recipient, error) {
+	rows, err := n.db.QueryContext(ctx, `
+		SELECT id, email
+		FROM users
+		WHERE notifications_enabled AND email <> ''
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recipients: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []recipient{}
+	for rows.Next() {
+		var rc recipient
+		if err := rows.Scan(&rc.userID, &rc.email); err != nil {
+			return nil, fmt.Errorf("failed to scan recipient: %w", err)
+		}
+		recipients = append(recipients, rc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate recipients: %w", err)
+	}
+	return recipients, nil
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"fif/tax"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// approachingRatio is how close to the threshold cost must be to warn
+const approachingRatio = 0.9
+
+// Alert kinds
+const (
+	KindThresholdApproaching = "threshold_approaching"
+	KindThresholdCrossed     = "threshold_crossed"
+	KindFilingDeadline       = "filing_deadline"
+	KindMissingPrices        = "missing_year_end_prices"
+)
+
+// deadlineReminders are the days before the filing deadline to remind users
+var deadlineReminders = []int{30, 7}
+
+// Input is what the rules need to know about one user
+type Input struct {
+	// CostNZD is the current cost of the user's FIF interests in NZD
+	CostNZD float64
+	// CostIncomplete is true when some FIF interests have no NZD cost, so
+	// CostNZD may be below the real figure
+	CostIncomplete bool
+	// HasHoldings is true when the user holds any instruments
+	HasHoldings bool
+	// MissingYearEndPrices lists symbols without a price on 31 March of the
+	// income year that has just ended
+	MissingYearEndPrices []string
+}
+
+// Alert is a notification to send. Key is unique per user and period so the
+// same alert is only ever sent once.
+type Alert struct {
+	Kind    string
+	Key     string
+	Subject string
+	Body    string
+}
+
+// Evaluate returns the alerts that apply to a user at now, going by the date
+// in New Zealand
+func Evaluate(now time.Time, in Input) []Alert {
+	today := tax.Date(now)
+	year := tax.IncomeYear(today)
+	alerts := []Alert{}
+
+	switch {
+	case in.CostNZD > tax.DeMinimisThreshold:
+		alerts = append(alerts, Alert{
+			Kind:    KindThresholdCrossed,
+			Key:     fmt.Sprintf("%s:%d", KindThresholdCrossed, year),
+			Subject: "Your FIF cost is over NZ$50,000",
+			Body: fmt.Sprintf("The cost of your foreign investments is now NZ$%.2f, which is over the "+
+				"NZ$50,000 de minimis threshold. You will need to calculate FIF income for the %d-%02d income year.",
+				in.CostNZD, year-1, year%100),
+		})
+	// An understated cost may already be over the threshold, so warning that
+	// it is approaching would mislead
+	case in.CostNZD >= tax.DeMinimisThreshold*approachingRatio && !in.CostIncomplete:
+		alerts = append(alerts, Alert{
+			Kind:    KindThresholdApproaching,
+			Key:     fmt.Sprintf("%s:%d", KindThresholdApproaching, year),
+			Subject: "Your FIF cost is approaching NZ$50,000",
+			Body: fmt.Sprintf("The cost of your foreign investments is NZ$%.2f, leaving NZ$%.2f before the "+
+				"NZ$50,000 de minimis threshold. A further purchase may bring you into the FIF rules.",
+				in.CostNZD, tax.DeMinimisThreshold-in.CostNZD),
+		})
+	}
+
+	// The return due on 7 July is for the income year that ended on 31 March
+	filingYear := year - 1
+	deadline := tax.FilingDeadline(filingYear)
+	daysLeft := int(deadline.Sub(today).Hours() / 24)
+	if in.HasHoldings && daysLeft >= 0 {
+		for i, days := range deadlineReminders {
+			later := -1
+			if i+1 < len(deadlineReminders) {
+				later = deadlineReminders[i+1]
+			}
+			if daysLeft <= days && daysLeft > later {
+				alerts = append(alerts, Alert{
+					Kind:    KindFilingDeadline,
+					Key:     fmt.Sprintf("%s:%d:%d", KindFilingDeadline, filingYear, days),
+					Subject: fmt.Sprintf("Your IR3 is due on %s", deadline.Format("2 January")),
+					Body: fmt.Sprintf("Your individual tax return for the year ended 31 March %d is due in %d days, "+
+						"on %s. Make sure any FIF income is included.",
+						filingYear, daysLeft, deadline.Format("2 January 2006")),
+				})
+				break
+			}
+		}
+	}
+
+	if len(in.MissingYearEndPrices) > 0 && !today.After(deadline) {
+		alerts = append(alerts, Alert{
+			Kind:    KindMissingPrices,
+			Key:     fmt.Sprintf("%s:%d", KindMissingPrices, filingYear),
+			Subject: "Year-end prices are missing",
+			Body: fmt.Sprintf("We don't have prices on 31 March %d for: %s. FIF income for the year can't be "+
+				"calculated until they are added.",
+				filingYear, strings.Join(in.MissingYearEndPrices, ", ")),
+		})
+	}
+
+	return alerts
+}
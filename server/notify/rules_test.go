@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatalf("Failed to parse date %q: %v", s, err)
+	}
+	return d
+}
+
+func kinds(alerts []Alert) map[string]Alert {
+	m := map[string]Alert{}
+	for _, a := range alerts {
+		m[a.Kind] = a
+	}
+	return m
+}
+
+func TestEvaluate_Threshold(t *testing.T) {
+	testCases := []struct {
+		name       string
+		cost       float64
+		incomplete bool
+		expected   string
+	}{
+		{name: "WellBelow", cost: 20000, expected: ""},
+		{name: "Approaching", cost: 46000, expected: KindThresholdApproaching},
+		{name: "AtThreshold", cost: 50000, expected: KindThresholdApproaching},
+		{name: "Crossed", cost: 50000.01, expected: KindThresholdCrossed},
+		{name: "ApproachingWithMissingCost", cost: 46000, incomplete: true, expected: ""},
+		{name: "CrossedWithMissingCost", cost: 50000.01, incomplete: true, expected: KindThresholdCrossed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts := kinds(Evaluate(day(t, "2025-10-01"), Input{CostNZD: tc.cost, CostIncomplete: tc.incomplete, HasHoldings: true}))
+
+			_, approaching := alerts[KindThresholdApproaching]
+			_, crossed := alerts[KindThresholdCrossed]
+			switch tc.expected {
+			case "":
+				if approaching || crossed {
+					t.Errorf("Expected no threshold alert, got %+v", alerts)
+				}
+			case KindThresholdApproaching:
+				if !approaching || crossed {
+					t.Errorf("Expected only approaching alert, got %+v", alerts)
+				}
+				if alerts[KindThresholdApproaching].Key != "threshold_approaching:2026" {
+					t.Errorf("Unexpected key %s", alerts[KindThresholdApproaching].Key)
+				}
+			case KindThresholdCrossed:
+				if approaching || !crossed {
+					t.Errorf("Expected only crossed alert, got %+v", alerts)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate_FilingDeadline(t *testing.T) {
+	testCases := []struct {
+		name        string
+		date        string
+		hasHoldings bool
+		expectedKey string
+	}{
+		{name: "TooEarly", date: "2025-06-06", hasHoldings: true, expectedKey: ""},
+		{name: "ThirtyDays", date: "2025-06-07", hasHoldings: true, expectedKey: "filing_deadline:2025:30"},
+		{name: "EightDays", date: "2025-06-29", hasHoldings: true, expectedKey: "filing_deadline:2025:30"},
+		{name: "SevenDays", date: "2025-06-30", hasHoldings: true, expectedKey: "filing_deadline:2025:7"},
+		{name: "DeadlineDay", date: "2025-07-07", hasHoldings: true, expectedKey: "filing_deadline:2025:7"},
+		{name: "AfterDeadline", date: "2025-07-08", hasHoldings: true, expectedKey: ""},
+		{name: "NoHoldings", date: "2025-06-30", hasHoldings: false, expectedKey: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			alerts := kinds(Evaluate(day(t, tc.date), Input{HasHoldings: tc.hasHoldings}))
+
+			alert, ok := alerts[KindFilingDeadline]
+			if tc.expectedKey == "" {
+				if ok {
+					t.Errorf("Expected no deadline alert, got %+v", alert)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("Expected a deadline alert")
+			}
+			if alert.Key != tc.expectedKey {
+				t.Errorf("Expected key %s, got %s", tc.expectedKey, alert.Key)
+			}
+		})
+	}
+}
+
+func TestEvaluate_MissingYearEndPrices(t *testing.T) {
+	in := Input{HasHoldings: true, MissingYearEndPrices: []string{"VTI", "AAPL"}}
+
+	alerts := kinds(Evaluate(day(t, "2025-04-15"), in))
+	alert, ok := alerts[KindMissingPrices]
+	if !ok {
+		t.Fatal("Expected a missing prices alert")
+	}
+	if alert.Key != "missing_year_end_prices:2025" {
+		t.Errorf("Unexpected key %s", alert.Key)
+	}
+
+	if _, ok := kinds(Evaluate(day(t, "2025-08-01"), in))[KindMissingPrices]; ok {
+		t.Error("Expected no missing prices alert after the filing deadline")
+	}
+}
+
+func TestEvaluate_UsesNewZealandDate(t *testing.T) {
+	// The job runs at 20:00 UTC, which is 08:00 the next day in New Zealand
+	now := time.Date(2025, time.June, 6, 20, 0, 0, 0, time.UTC)
+
+	alerts := kinds(Evaluate(now, Input{HasHoldings: true}))
+
+	// Assert the 30 day reminder goes out on 7 June in New Zealand
+	if alert, ok := alerts[KindFilingDeadline]; !ok || alert.Key != "filing_deadline:2025:30" {
+		t.Errorf("Expected the 30 day reminder, got %+v", alerts)
+	}
+}
@@ -0,0 +1,35 @@
+package notify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogSender_WritesOutbox(t *testing.T) {
+	dir := t.TempDir()
+	sender := &LogSender{Dir: dir}
+
+	msg := Message{To: "test@example.com", Subject: "Hello", Body: "line one\nline two"}
+	if err := sender.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one .eml file, got %v (err %v)", files, err)
+	}
+
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("Failed to read email: %v", err)
+	}
+
+	for _, expected := range []string{"To: test@example.com\r\n", "Subject: Hello\r\n", "line one\r\nline two"} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected email to contain %q, got %q", expected, content)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package portfolio
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// BackfillJob is the scheduled job that runs queued backfills
+const BackfillJob = "portfolio-backfill"
+
+// Backfill statuses
+const (
+	BackfillPending   = "pending"
+	BackfillRunning   = "running"
+	BackfillSucceeded = "succeeded"
+	BackfillFailed    = "failed"
+)
+
+// ErrBackfillNotFound is returned when a backfill does not exist or belongs to
+// someone else
+var ErrBackfillNotFound = errors.New("backfill not found")
+
+// BackfillRequest is a queued backfill of a user's history
+type BackfillRequest struct {
+	ID         int64      `json:"id"`
+	From       string     `json:"from"`
+	To         string     `json:"to"`
+	Status     string     `json:"status"`
+	Days       int        `json:"days"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// BackfillQueue stores backfill requests for BackfillJob to run
+type BackfillQueue struct {
+	db *sql.DB
+}
+
+// NewBackfillQueue creates a BackfillQueue backed by db
+func NewBackfillQueue(db *sql.DB) *BackfillQueue {
+	return &BackfillQueue{db: db}
+}
+
+const backfillColumns = `id, from_date, to_date, status, days, COALESCE(error, ''), created_at, started_at, finished_at`
+
+// Enqueue records a request to backfill userID's history from from to to
+func (q *BackfillQueue) Enqueue(ctx context.Context, userID string, from, to time.Time) (BackfillRequest, error) {
+	row := q.db.QueryRowContext(ctx, `
+		INSERT INTO portfolio_backfills (user_id, from_date, to_date)
+		VALUES ($1, $2, $3)
+		RETURNING `+backfillColumns,
+		userID, from.Format(DateLayout), to.Format(DateLayout))
+	b, err := scanBackfill(row)
+	if err != nil {
+		return b, fmt.Errorf("failed to queue backfill: %w", err)
+	}
+	return b, nil
+}
+
+// Get returns one of userID's backfills
+func (q *BackfillQueue) Get(ctx context.Context, userID string, id int64) (BackfillRequest, error) {
+	row := q.db.QueryRowContext(ctx, `
+		SELECT `+backfillColumns+`
+		FROM portfolio_backfills
+		WHERE id = $1 AND user_id = $2
+	`, id, userID)
+	b, err := scanBackfill(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return b, ErrBackfillNotFound
+	}
+	if err != nil {
+		return b, fmt.Errorf("failed to load backfill: %w", err)
+	}
+	return b, nil
+}
+
+// Run works through pending backfills, oldest first, until none are left. It
+// is only called by BackfillJob, whose lease stops replicas running it at the
+// same time, so backfills still marked running were interrupted and are retried.
+func (q *BackfillQueue) Run(ctx context.Context) (int, error) {
+	if _, err := q.db.ExecContext(ctx, `
+		UPDATE portfolio_backfills SET status = 'pending' WHERE status = 'running'
+	`); err != nil {
+		return 0, fmt.Errorf("failed to requeue interrupted backfills: %w", err)
+	}
+
+	count := 0
+	for {
+		var id int64
+		var userID string
+		var from, to time.Time
+		err := q.db.QueryRowContext(ctx, `
+			UPDATE portfolio_backfills SET status = 'running', started_at = NOW(), days = 0, error = NULL
+			WHERE id = (SELECT MIN(id) FROM portfolio_backfills WHERE status = 'pending')
+			RETURNING id, user_id, from_date, to_date
+		`).Scan(&id, &userID, &from, &to)
+		if errors.Is(err, sql.ErrNoRows) {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to claim backfill: %w", err)
+		}
+
+		days, runErr := Backfill(ctx, q.db, userID, from, to)
+
+		// Recorded with a fresh context so a cancelled run is still saved
+		finish := context.WithoutCancel(ctx)
+		status, msg := BackfillSucceeded, sql.NullString{}
+		switch {
+		case ctx.Err() != nil:
+			// Interrupted by shutdown or the job timeout; the next run retries
+			status = BackfillPending
+		case runErr != nil:
+			status, msg = BackfillFailed, sql.NullString{String: runErr.Error(), Valid: true}
+			slog.ErrorContext(ctx, "Error backfilling portfolio", "uid", userID, "backfill", id, "error", runErr)
+		}
+		if _, err := q.db.ExecContext(finish, `
+			UPDATE portfolio_backfills
+			SET status = $2, days = $3, error = $4,
+				finished_at = CASE WHEN $2 = 'pending' THEN NULL ELSE NOW() END
+			WHERE id = $1
+		`, id, status, days, msg); err != nil {
+			return count, fmt.Errorf("failed to record backfill: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func scanBackfill(row *sql.Row) (BackfillRequest, error) {
+	var b BackfillRequest
+	var from, to time.Time
+	var startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&b.ID, &from, &to, &b.Status, &b.Days, &b.Error, &b.CreatedAt, &startedAt, &finishedAt); err != nil {
+		return b, err
+	}
+	b.From = from.Format(DateLayout)
+	b.To = to.Format(DateLayout)
+	if startedAt.Valid {
+		b.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		b.FinishedAt = &finishedAt.Time
+	}
+	return b, nil
+}
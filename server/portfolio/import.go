@@ -0,0 +1,159 @@
+package portfolio
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// requiredColumns must be in every CSV ParseHoldingsCSV reads. name may also
+// be given and defaults to the symbol. cost_nzd, what the cost came to in NZD
+// when it was paid, may be given too; otherwise the rate on the day of the
+// import is used.
+var requiredColumns = []string{"symbol", "quantity", "currency", "cost"}
+
+var (
+	symbolPattern   = regexp.MustCompile(`^[A-Z0-9.\-]{1,16}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// RowError is an invalid row of an import file
+type RowError struct {
+	Line    int
+	Message string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ParseHoldingsCSV reads holdings from CSV with a header row naming the
+// columns, in any order and case. Broker exports are converted to this layout
+// for import. Every invalid row is reported, not just the first.
+func ParseHoldingsCSV(r io.Reader) ([]Holding, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	index := map[string]int{}
+	for i, col := range header {
+		index[strings.ToLower(strings.TrimSpace(col))] = i
+	}
+	for _, col := range requiredColumns {
+		if _, ok := index[col]; !ok {
+			return nil, fmt.Errorf("missing column %q", col)
+		}
+	}
+
+	field := func(record []string, col string) string {
+		if i, ok := index[col]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var holdings []Holding
+	var errs []error
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		h := Holding{
+			Name:     field(record, "name"),
+			Symbol:   strings.ToUpper(field(record, "symbol")),
+			Currency: strings.ToUpper(field(record, "currency")),
+		}
+		if h.Name == "" {
+			h.Name = h.Symbol
+		}
+
+		var problems []string
+		if !symbolPattern.MatchString(h.Symbol) {
+			problems = append(problems, "symbol must be 1-16 letters, digits, dots or dashes")
+		}
+		if !currencyPattern.MatchString(h.Currency) {
+			problems = append(problems, "currency must be a three letter code")
+		}
+		var ok bool
+		if h.Quantity, ok = parseAmount(field(record, "quantity")); !ok {
+			problems = append(problems, "quantity must be a number of at least 0")
+		}
+		if h.Cost, ok = parseAmount(field(record, "cost")); !ok {
+			problems = append(problems, "cost must be a number of at least 0")
+		}
+		if v := field(record, "cost_nzd"); v != "" {
+			if costNZD, ok := parseAmount(v); ok {
+				h.CostNZD = &costNZD
+			} else {
+				problems = append(problems, "cost_nzd must be a number of at least 0")
+			}
+		}
+		if len(problems) > 0 {
+			errs = append(errs, &RowError{Line: line, Message: strings.Join(problems, "; ")})
+			continue
+		}
+		holdings = append(holdings, h)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return holdings, nil
+}
+
+// parseAmount reads a quantity or cost. ParseFloat accepts NaN and Inf, which
+// Postgres would store, so only finite numbers of at least 0 are allowed.
+func parseAmount(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && v >= 0 && !math.IsInf(v, 0)
+}
+
+// ImportHoldings adds holdings for a user in one transaction. With replace
+// the user's existing holdings are deleted first. A holding without CostNZD
+// gets one from today's rate in the database.
+func ImportHoldings(ctx context.Context, db *sql.DB, userID string, holdings []Holding, replace bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := ImportHoldingsTx(ctx, tx, userID, holdings, replace); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ImportHoldingsTx is ImportHoldings within the caller's transaction
+func ImportHoldingsTx(ctx context.Context, tx *sql.Tx, userID string, holdings []Holding, replace bool) error {
+	if replace {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM holdings WHERE user_id = $1`, userID); err != nil {
+			return fmt.Errorf("failed to delete holdings: %w", err)
+		}
+	}
+
+	for _, h := range holdings {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO holdings (user_id, name, symbol, quantity, currency, cost, cost_nzd)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, userID, h.Name, h.Symbol, h.Quantity, h.Currency, h.Cost, h.CostNZD); err != nil {
+			return fmt.Errorf("failed to insert holding %s: %w", h.Symbol, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package portfolio
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseHoldingsCSV(t *testing.T) {
+	input := "Symbol,Quantity,Currency,Cost,Name\n" +
+		"vti, 12.5, usd, 2500, Vanguard Total Stock Market ETF\n" +
+		"AAPL,20,USD,3000,\n"
+
+	holdings, err := ParseHoldingsCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Assert columns are matched by name and values normalised
+	if len(holdings) != 2 {
+		t.Fatalf("Expected 2 holdings, got %d", len(holdings))
+	}
+	if holdings[0].Symbol != "VTI" || holdings[0].Currency != "USD" || holdings[0].Quantity != 12.5 {
+		t.Errorf("Unexpected first holding %+v", holdings[0])
+	}
+	if holdings[1].Name != "AAPL" {
+		t.Errorf("Expected name to default to the symbol, got %q", holdings[1].Name)
+	}
+}
+
+func TestParseHoldingsCSV_ReportsEveryBadRow(t *testing.T) {
+	input := "symbol,quantity,currency,cost\n" +
+		"VTI,ten,USD,2500\n" +
+		"AAPL,20,USD,3000\n" +
+		"TSLA,5,dollars,-1\n"
+
+	_, err := ParseHoldingsCSV(strings.NewReader(input))
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+
+	for _, expected := range []string{"line 2: quantity", "line 4: currency", "cost must be"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestParseHoldingsCSV_CostNZD(t *testing.T) {
+	input := "symbol,quantity,currency,cost,cost_nzd\n" +
+		"VTI,1,USD,250,410.50\n" +
+		"AAPL,1,USD,200,\n" +
+		"TSLA,1,USD,100,lots\n"
+
+	_, err := ParseHoldingsCSV(strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "line 4: cost_nzd") {
+		t.Fatalf("Expected an error for line 4, got %v", err)
+	}
+
+	holdings, err := ParseHoldingsCSV(strings.NewReader(strings.TrimSuffix(input, "TSLA,1,USD,100,lots\n")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Assert a given NZD cost is kept and a blank one left to the database
+	if holdings[0].CostNZD == nil || *holdings[0].CostNZD != 410.50 {
+		t.Errorf("Expected NZD cost 410.50, got %v", holdings[0].CostNZD)
+	}
+	if holdings[1].CostNZD != nil {
+		t.Errorf("Expected no NZD cost, got %v", *holdings[1].CostNZD)
+	}
+}
+
+func TestParseHoldingsCSV_RejectsNaNAndInf(t *testing.T) {
+	input := "symbol,quantity,currency,cost\n" +
+		"VTI,NaN,USD,2500\n" +
+		"AAPL,20,USD,+Inf\n"
+
+	_, err := ParseHoldingsCSV(strings.NewReader(input))
+
+	// Assert both rows are rejected
+	if err == nil {
+		t.Fatal("Expected an error")
+	}
+	for _, expected := range []string{"line 2: quantity", "line 3: cost"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestRowErrors(t *testing.T) {
+	input := "symbol,quantity,currency,cost\n" +
+		"VTI,ten,USD,2500\n" +
+		"AAPL,20,USD,3000\n" +
+		"TSLA,5,USD,-1\n"
+
+	_, err := ParseHoldingsCSV(strings.NewReader(input))
+
+	// Assert each bad row can be recorded on its own
+	rows := RowErrors(err)
+	if len(rows) != 2 || rows[0].Line != 2 || rows[1].Line != 4 {
+		t.Fatalf("Expected rows 2 and 4, got %+v", rows)
+	}
+	if !strings.HasPrefix(rows[1].Message, "cost") {
+		t.Errorf("Expected the message without the line, got %q", rows[1].Message)
+	}
+
+	// Assert a failure that is not about rows has none
+	if rows := RowErrors(errors.New("missing column")); len(rows) != 0 {
+		t.Errorf("Expected no rows, got %+v", rows)
+	}
+}
+
+func TestParseHoldingsCSV_MissingColumn(t *testing.T) {
+	if _, err := ParseHoldingsCSV(strings.NewReader("symbol,quantity,cost\nVTI,1,1\n")); err == nil {
+		t.Error("Expected an error for a missing currency column")
+	}
+}
@@ -0,0 +1,102 @@
+package portfolio
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ImportFailure is a row, or a whole file, that could not be imported
+type ImportFailure struct {
+	ID     int64  `json:"id"`
+	UserID string `json:"userId"`
+	Source string `json:"source"`
+	// Line is nil when the file as a whole failed
+	Line      *int      `json:"line,omitempty"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RecordImportFailure saves why an import for userID failed: one failure per
+// invalid row, or one for the file when importErr is not about rows
+func RecordImportFailure(ctx context.Context, db *sql.DB, userID, source string, importErr error) error {
+	rows := RowErrors(importErr)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := func(line *int, msg string) error {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO import_failures (user_id, source, line, error)
+			VALUES ($1, $2, $3, $4)
+		`, userID, source, line, msg); err != nil {
+			return fmt.Errorf("failed to record import failure: %w", err)
+		}
+		return nil
+	}
+
+	if len(rows) == 0 {
+		if err := insert(nil, importErr.Error()); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		if err := insert(&row.Line, row.Message); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RowErrors returns the invalid rows reported in err
+func RowErrors(err error) []*RowError {
+	var rows []*RowError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			rows = append(rows, RowErrors(e)...)
+		}
+		return rows
+	}
+
+	var row *RowError
+	if errors.As(err, &row) {
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// ListImportFailures returns the latest failures, newest first, for one user
+// or for everyone when userID is empty
+func ListImportFailures(ctx context.Context, db *sql.DB, userID string, limit int) ([]ImportFailure, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, user_id, source, line, error, created_at
+		FROM import_failures
+		WHERE $1 = '' OR user_id = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import failures: %w", err)
+	}
+	defer rows.Close()
+
+	failures := []ImportFailure{}
+	for rows.Next() {
+		var f ImportFailure
+		var line sql.NullInt64
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Source, &line, &f.Error, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan import failure: %w", err)
+		}
+		if line.Valid {
+			n := int(line.Int64)
+			f.Line = &n
+		}
+		failures = append(failures, f)
+	}
+	return failures, rows.Err()
+}
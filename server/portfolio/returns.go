@@ -0,0 +1,411 @@
+package portfolio
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fif/metrics"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ReturnPoint is a portfolio or holding value at the end of a day, including
+// the net external contribution (positive) or withdrawal (negative) that day
+type ReturnPoint struct {
+	Date  time.Time
+	Value float64
+	Flow  float64
+}
+
+// Returns summarises performance over a series of points. TWR is cumulative
+// over the range; XIRR is annualised. Either is nil when it cannot be computed.
+type Returns struct {
+	StartValue float64  `json:"startValue"`
+	EndValue   float64  `json:"endValue"`
+	NetFlows   float64  `json:"netFlows"`
+	TWR        *float64 `json:"twr"`
+	XIRR       *float64 `json:"xirr"`
+}
+
+// HoldingReturns reports one instrument's returns in its own currency and in NZD.
+// FXEffect is the NZD TWR less the original currency TWR.
+type HoldingReturns struct {
+	Symbol   string   `json:"symbol"`
+	Currency string   `json:"currency"`
+	Original Returns  `json:"original"`
+	NZD      Returns  `json:"nzd"`
+	FXEffect *float64 `json:"fxEffect"`
+}
+
+// BenchmarkReturn is the price return of a benchmark symbol over the range
+type BenchmarkReturn struct {
+	Symbol string   `json:"symbol"`
+	Return *float64 `json:"return"`
+}
+
+// ReturnsReport is the performance of a portfolio between two dates.
+// FlowsInferred is set when contributions and withdrawals were estimated from
+// snapshots rather than read from a ledger, so the returns are approximate.
+type ReturnsReport struct {
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	Portfolio     Returns          `json:"portfolio"`
+	Holdings      []HoldingReturns `json:"holdings"`
+	Benchmark     *BenchmarkReturn `json:"benchmark,omitempty"`
+	FlowsInferred bool             `json:"flowsInferred"`
+}
+
+// DatedSnapshotRow is a stored snapshot row with its date
+type DatedSnapshotRow struct {
+	Date time.Time
+	SnapshotRow
+}
+
+// ErrNoXIRR is returned when the cash flows have no internal rate of return
+var ErrNoXIRR = errors.New("xirr did not converge")
+
+// TWR chains daily returns, treating each day's flow as happening at the end of
+// the day. Days following a zero value are skipped.
+func TWR(points []ReturnPoint) (float64, bool) {
+	if len(points) < 2 {
+		return 0, false
+	}
+
+	growth := 1.0
+	periods := 0
+	for i := 1; i < len(points); i++ {
+		prev := points[i-1].Value
+		if prev <= 0 {
+			continue
+		}
+		growth *= (points[i].Value - points[i].Flow) / prev
+		periods++
+	}
+	if periods == 0 {
+		return 0, false
+	}
+	return growth - 1, true
+}
+
+// XIRR solves for the annual rate that discounts the investor's cash flows to
+// zero: the starting value and contributions paid in, the end value paid out.
+func XIRR(points []ReturnPoint) (float64, error) {
+	if len(points) < 2 {
+		return 0, ErrNoXIRR
+	}
+
+	start := points[0].Date
+	last := len(points) - 1
+	if !points[last].Date.After(start) {
+		return 0, ErrNoXIRR
+	}
+
+	type cashFlow struct {
+		years  float64
+		amount float64
+	}
+	flows := []cashFlow{{0, -points[0].Value}}
+	for i := 1; i < len(points); i++ {
+		years := points[i].Date.Sub(start).Hours() / 24 / 365
+		amount := -points[i].Flow
+		if i == last {
+			amount += points[i].Value
+		}
+		flows = append(flows, cashFlow{years, amount})
+	}
+
+	npv := func(rate float64) float64 {
+		sum := 0.0
+		for _, f := range flows {
+			sum += f.amount / math.Pow(1+rate, f.years)
+		}
+		return sum
+	}
+	dnpv := func(rate float64) float64 {
+		sum := 0.0
+		for _, f := range flows {
+			sum -= f.years * f.amount / math.Pow(1+rate, f.years+1)
+		}
+		return sum
+	}
+
+	// Newton's method converges quickly for ordinary portfolios
+	rate := 0.1
+	for i := 0; i < 50; i++ {
+		d := dnpv(rate)
+		if d == 0 || math.IsNaN(d) {
+			break
+		}
+		next := rate - npv(rate)/d
+		if next <= -1 || math.IsNaN(next) || math.IsInf(next, 0) {
+			break
+		}
+		if math.Abs(next-rate) < 1e-10 {
+			return next, nil
+		}
+		rate = next
+	}
+
+	// Fall back to bisection when Newton wanders off
+	lo, hi := -0.9999, 100.0
+	fLo, fHi := npv(lo), npv(hi)
+	if math.IsNaN(fLo) || math.IsNaN(fHi) || fLo*fHi > 0 {
+		return 0, ErrNoXIRR
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		fMid := npv(mid)
+		if math.Abs(fMid) < 1e-9 || hi-lo < 1e-12 {
+			return mid, nil
+		}
+		if fLo*fMid < 0 {
+			hi = mid
+		} else {
+			lo, fLo = mid, fMid
+		}
+	}
+	return (lo + hi) / 2, nil
+}
+
+// Summarise computes Returns for a series of points
+func Summarise(points []ReturnPoint) Returns {
+	r := Returns{}
+	if len(points) == 0 {
+		return r
+	}
+
+	r.StartValue = points[0].Value
+	r.EndValue = points[len(points)-1].Value
+	for _, p := range points[1:] {
+		r.NetFlows += p.Flow
+	}
+
+	if twr, ok := TWR(points); ok {
+		r.TWR = float64Ptr(twr)
+	}
+	if xirr, err := XIRR(points); err == nil {
+		r.XIRR = float64Ptr(xirr)
+	}
+	return r
+}
+
+// series builds ReturnPoints, inferring each day's flow from the change in
+// quantity since the last point. Cost is in original currency and is converted
+// at the given rate, so FX moves on existing cost are not counted as flows.
+type series struct {
+	points       []ReturnPoint
+	lastQuantity float64
+	lastCost     float64
+}
+
+func (s *series) add(date time.Time, value, quantity, cost, rate float64) {
+	flow := 0.0
+	if n := len(s.points); n > 0 {
+		flow = inferFlow(s.lastQuantity, s.lastCost, s.points[n-1].Value, quantity, cost, value, rate)
+	}
+	s.points = append(s.points, ReturnPoint{Date: date, Value: value, Flow: flow})
+	s.lastQuantity = quantity
+	s.lastCost = cost
+}
+
+// inferFlow estimates the contribution or withdrawal between two days of one
+// holding. Units bought are paid for at the rise in cost, converted at rate.
+// Units sold are taken out at market value, priced from the later day or, when
+// none are left, from the earlier one. A change in cost alone is not a flow.
+func inferFlow(prevQuantity, prevCost, prevValue, quantity, cost, value, rate float64) float64 {
+	switch {
+	case quantity > prevQuantity:
+		return (cost - prevCost) / rate
+	case quantity < prevQuantity && quantity > 0:
+		return -(prevQuantity - quantity) * value / quantity
+	case quantity < prevQuantity:
+		return -prevValue
+	}
+	return 0
+}
+
+// snapshotRate recovers the units of currency per NZD used to value a snapshot
+// row. Cost in NZD is at purchase rates, so it is only used without a value.
+func snapshotRate(row SnapshotRow) (float64, bool) {
+	if row.MarketValue != nil && row.MarketValueNZD != nil && *row.MarketValue != 0 && *row.MarketValueNZD != 0 {
+		return *row.MarketValue / *row.MarketValueNZD, true
+	}
+	if row.CostNZD != nil && *row.CostNZD != 0 {
+		return row.Cost / *row.CostNZD, true
+	}
+	return 0, false
+}
+
+// ComputeReturns builds portfolio and per holding returns from snapshot rows
+// sorted by date. There is no transaction ledger, so external flows are
+// inferred from day to day changes in quantity, and instruments that leave the
+// portfolio are withdrawn at their last market value. Days with a missing
+// price or rate are skipped, and the next day's flow covers the gap.
+func ComputeReturns(rows []DatedSnapshotRow) ReturnsReport {
+	defer metrics.ObserveCalculation("returns", time.Now())
+
+	type key struct{ symbol, currency string }
+	type holdingState struct {
+		original series
+		nzd      series
+	}
+	// position is a holding on the last complete portfolio day
+	type position struct {
+		quantity, cost, value float64
+	}
+
+	holdings := map[key]*holdingState{}
+	order := []key{}
+
+	var port []ReturnPoint
+	positions := map[key]position{}
+
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].Date.Equal(rows[start].Date) {
+			end++
+		}
+		day := rows[start:end]
+		date := day[0].Date
+
+		complete := true
+		value := 0.0
+		rates := map[key]float64{}
+
+		for _, row := range day {
+			k := key{row.Symbol, row.Currency}
+
+			st, ok := holdings[k]
+			if !ok {
+				st = &holdingState{}
+				holdings[k] = st
+				order = append(order, k)
+			}
+
+			if row.MarketValue != nil {
+				st.original.add(date, *row.MarketValue, row.Quantity, row.Cost, 1)
+			}
+
+			rate, ok := snapshotRate(row.SnapshotRow)
+			if ok && row.MarketValueNZD != nil {
+				st.nzd.add(date, *row.MarketValueNZD, row.Quantity, row.Cost, rate)
+				value += *row.MarketValueNZD
+				rates[k] = rate
+			} else {
+				complete = false
+			}
+		}
+
+		if complete {
+			flow := 0.0
+			next := map[key]position{}
+			for _, row := range day {
+				k := key{row.Symbol, row.Currency}
+				p := positions[k]
+				flow += inferFlow(p.quantity, p.cost, p.value, row.Quantity, row.Cost, *row.MarketValueNZD, rates[k])
+				next[k] = position{row.Quantity, row.Cost, *row.MarketValueNZD}
+			}
+			// Instruments that have left the portfolio were sold at their last value
+			for k, p := range positions {
+				if _, ok := next[k]; !ok {
+					flow -= p.value
+				}
+			}
+			positions = next
+			if len(port) == 0 {
+				flow = 0
+			}
+			port = append(port, ReturnPoint{Date: date, Value: value, Flow: flow})
+		}
+
+		start = end
+	}
+
+	report := ReturnsReport{
+		Portfolio:     Summarise(port),
+		Holdings:      make([]HoldingReturns, 0, len(order)),
+		FlowsInferred: true,
+	}
+	if len(rows) > 0 {
+		report.From = rows[0].Date.Format(DateLayout)
+		report.To = rows[len(rows)-1].Date.Format(DateLayout)
+	}
+
+	for _, k := range order {
+		st := holdings[k]
+		hr := HoldingReturns{
+			Symbol:   k.symbol,
+			Currency: k.currency,
+			Original: Summarise(st.original.points),
+			NZD:      Summarise(st.nzd.points),
+		}
+		if hr.Original.TWR != nil && hr.NZD.TWR != nil {
+			hr.FXEffect = float64Ptr(*hr.NZD.TWR - *hr.Original.TWR)
+		}
+		report.Holdings = append(report.Holdings, hr)
+	}
+
+	return report
+}
+
+// LoadSnapshotRows returns a user's snapshot rows between from and to inclusive
+func LoadSnapshotRows(ctx context.Context, db *sql.DB, userID string, from, to time.Time) ([]DatedSnapshotRow, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT snapshot_date, symbol, currency, quantity, cost, market_value, cost_nzd, market_value_nzd
+		FROM portfolio_snapshots
+		WHERE user_id = $1 AND snapshot_date BETWEEN $2 AND $3
+		ORDER BY snapshot_date, symbol, currency
+	`, userID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	result := []DatedSnapshotRow{}
+	for rows.Next() {
+		var r DatedSnapshotRow
+		var marketValue, costNZD, marketValueNZD sql.NullFloat64
+		if err := rows.Scan(&r.Date, &r.Symbol, &r.Currency, &r.Quantity, &r.Cost,
+			&marketValue, &costNZD, &marketValueNZD); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		r.MarketValue = nullFloat64Ptr(marketValue)
+		r.CostNZD = nullFloat64Ptr(costNZD)
+		r.MarketValueNZD = nullFloat64Ptr(marketValueNZD)
+		result = append(result, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate snapshots: %w", err)
+	}
+	return result, nil
+}
+
+// LoadBenchmark computes the price return of symbol between from and to using
+// the latest stored close on or before each date
+func LoadBenchmark(ctx context.Context, db *sql.DB, symbol string, from, to time.Time) (BenchmarkReturn, error) {
+	b := BenchmarkReturn{Symbol: symbol}
+
+	start, err := LoadPrices(ctx, db, []string{symbol}, from)
+	if err != nil {
+		return b, err
+	}
+	end, err := LoadPrices(ctx, db, []string{symbol}, to)
+	if err != nil {
+		return b, err
+	}
+
+	s, okStart := start[symbol]
+	e, okEnd := end[symbol]
+	if okStart && okEnd && s.Value > 0 {
+		b.Return = float64Ptr(e.Value/s.Value - 1)
+	}
+	return b, nil
+}
+
+func nullFloat64Ptr(n sql.NullFloat64) *float64 {
+	if !n.Valid {
+		return nil
+	}
+	return float64Ptr(n.Float64)
+}
@@ -0,0 +1,182 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTWR_WithContribution(t *testing.T) {
+	points := []ReturnPoint{
+		{Date: mustDate(t, "2024-04-01"), Value: 100},
+		{Date: mustDate(t, "2024-10-01"), Value: 205, Flow: 100}, // grew 5% then added 100
+		{Date: mustDate(t, "2025-03-31"), Value: 215.25},         // grew another 5%
+	}
+
+	twr, ok := TWR(points)
+	if !ok {
+		t.Fatal("Expected TWR to be computed")
+	}
+	if !approxEqual(twr, 0.1025) {
+		t.Errorf("Expected TWR 0.1025, got %f", twr)
+	}
+}
+
+func TestTWR_TooFewPoints(t *testing.T) {
+	if _, ok := TWR([]ReturnPoint{{Date: mustDate(t, "2024-04-01"), Value: 100}}); ok {
+		t.Error("Expected no TWR for a single point")
+	}
+}
+
+func TestXIRR_SingleYear(t *testing.T) {
+	points := []ReturnPoint{
+		{Date: mustDate(t, "2024-01-01"), Value: 100},
+		{Date: mustDate(t, "2024-12-31"), Value: 110}, // 365 days later
+	}
+
+	xirr, err := XIRR(points)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(xirr-0.1) > 1e-6 {
+		t.Errorf("Expected XIRR 0.1, got %f", xirr)
+	}
+}
+
+func TestXIRR_WithContribution(t *testing.T) {
+	points := []ReturnPoint{
+		{Date: mustDate(t, "2024-01-01"), Value: 1000},
+		{Date: mustDate(t, "2024-07-01"), Value: 2050, Flow: 1000},
+		{Date: mustDate(t, "2024-12-31"), Value: 2200},
+	}
+
+	xirr, err := XIRR(points)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The rate must discount the cash flows to zero
+	npv := -1000.0 - 1000/math.Pow(1+xirr, 182.0/365) + 2200/math.Pow(1+xirr, 365.0/365)
+	if math.Abs(npv) > 1e-6 {
+		t.Errorf("Expected NPV 0 at XIRR %f, got %f", xirr, npv)
+	}
+}
+
+func TestXIRR_SameDay(t *testing.T) {
+	points := []ReturnPoint{
+		{Date: mustDate(t, "2024-01-01"), Value: 100},
+		{Date: mustDate(t, "2024-01-01"), Value: 110},
+	}
+
+	if _, err := XIRR(points); err != ErrNoXIRR {
+		t.Errorf("Expected ErrNoXIRR, got %v", err)
+	}
+}
+
+func TestComputeReturns_SeparatesFXEffect(t *testing.T) {
+	// Price is flat in USD but NZD weakens from 0.6 to 0.5 USD per NZD
+	rows := []DatedSnapshotRow{
+		{Date: mustDate(t, "2024-04-01"), SnapshotRow: SnapshotRow{
+			Symbol: "VTI", Currency: "USD", Quantity: 10, Cost: 600,
+			MarketValue: float64Ptr(600), CostNZD: float64Ptr(1000), MarketValueNZD: float64Ptr(1000),
+		}},
+		{Date: mustDate(t, "2025-03-31"), SnapshotRow: SnapshotRow{
+			Symbol: "VTI", Currency: "USD", Quantity: 10, Cost: 600,
+			MarketValue: float64Ptr(600), CostNZD: float64Ptr(1000), MarketValueNZD: float64Ptr(1200),
+		}},
+	}
+
+	report := ComputeReturns(rows)
+
+	if len(report.Holdings) != 1 {
+		t.Fatalf("Expected 1 holding, got %d", len(report.Holdings))
+	}
+
+	h := report.Holdings[0]
+	if h.Original.TWR == nil || !approxEqual(*h.Original.TWR, 0) {
+		t.Errorf("Expected original TWR 0, got %v", h.Original.TWR)
+	}
+	if h.NZD.TWR == nil || !approxEqual(*h.NZD.TWR, 0.2) {
+		t.Errorf("Expected NZD TWR 0.2, got %v", h.NZD.TWR)
+	}
+	if h.FXEffect == nil || !approxEqual(*h.FXEffect, 0.2) {
+		t.Errorf("Expected FX effect 0.2, got %v", h.FXEffect)
+	}
+
+	if report.Portfolio.TWR == nil || !approxEqual(*report.Portfolio.TWR, 0.2) {
+		t.Errorf("Expected portfolio TWR 0.2, got %v", report.Portfolio.TWR)
+	}
+	if !approxEqual(report.Portfolio.NetFlows, 0) {
+		t.Errorf("Expected no net flows, got %f", report.Portfolio.NetFlows)
+	}
+}
+
+func TestComputeReturns_PurchaseIsAFlow(t *testing.T) {
+	rows := []DatedSnapshotRow{
+		{Date: mustDate(t, "2024-04-01"), SnapshotRow: SnapshotRow{
+			Symbol: "FPH", Currency: "NZD", Quantity: 10, Cost: 100,
+			MarketValue: float64Ptr(100), CostNZD: float64Ptr(100), MarketValueNZD: float64Ptr(100),
+		}},
+		// A second instrument is bought for 50 and the first grows 10%
+		{Date: mustDate(t, "2024-04-02"), SnapshotRow: SnapshotRow{
+			Symbol: "FPH", Currency: "NZD", Quantity: 10, Cost: 100,
+			MarketValue: float64Ptr(110), CostNZD: float64Ptr(100), MarketValueNZD: float64Ptr(110),
+		}},
+		{Date: mustDate(t, "2024-04-02"), SnapshotRow: SnapshotRow{
+			Symbol: "SPK", Currency: "NZD", Quantity: 10, Cost: 50,
+			MarketValue: float64Ptr(50), CostNZD: float64Ptr(50), MarketValueNZD: float64Ptr(50),
+		}},
+	}
+
+	report := ComputeReturns(rows)
+
+	if !approxEqual(report.Portfolio.NetFlows, 50) {
+		t.Errorf("Expected net flows 50, got %f", report.Portfolio.NetFlows)
+	}
+	if report.Portfolio.TWR == nil || !approxEqual(*report.Portfolio.TWR, 0.1) {
+		t.Errorf("Expected portfolio TWR 0.1, got %v", report.Portfolio.TWR)
+	}
+	if len(report.Holdings) != 2 {
+		t.Errorf("Expected 2 holdings, got %d", len(report.Holdings))
+	}
+}
+
+func TestComputeReturns_SalesAtMarketValue(t *testing.T) {
+	row := func(date, symbol string, quantity, cost, value float64) DatedSnapshotRow {
+		return DatedSnapshotRow{Date: mustDate(t, date), SnapshotRow: SnapshotRow{
+			Symbol: symbol, Currency: "NZD", Quantity: quantity, Cost: cost,
+			MarketValue: float64Ptr(value), CostNZD: float64Ptr(cost), MarketValueNZD: float64Ptr(value),
+		}}
+	}
+
+	// Create two holdings where FPH rises 50% and is then sold, in part or in full
+	base := []DatedSnapshotRow{
+		row("2024-04-01", "FPH", 10, 100, 100),
+		row("2024-04-01", "SPK", 10, 100, 100),
+		row("2024-04-02", "FPH", 10, 100, 150),
+		row("2024-04-02", "SPK", 10, 100, 100),
+	}
+	partial := append(append([]DatedSnapshotRow{}, base...),
+		row("2024-04-03", "FPH", 5, 50, 75),
+		row("2024-04-03", "SPK", 10, 100, 100),
+	)
+	full := append(append([]DatedSnapshotRow{}, base...),
+		row("2024-04-03", "SPK", 10, 100, 100),
+	)
+
+	// Assert the gain stays a return and the proceeds are the withdrawal
+	for name, rows := range map[string][]DatedSnapshotRow{"partial": partial, "full": full} {
+		report := ComputeReturns(rows)
+		if report.Portfolio.TWR == nil || !approxEqual(*report.Portfolio.TWR, 0.25) {
+			t.Errorf("%s: expected portfolio TWR 0.25, got %v", name, report.Portfolio.TWR)
+		}
+		if !report.FlowsInferred {
+			t.Errorf("%s: expected flows to be reported as inferred", name)
+		}
+	}
+	if flows := ComputeReturns(partial).Portfolio.NetFlows; !approxEqual(flows, -75) {
+		t.Errorf("Expected partial sale proceeds of 75, got %f", flows)
+	}
+	if flows := ComputeReturns(full).Portfolio.NetFlows; !approxEqual(flows, -150) {
+		t.Errorf("Expected full sale proceeds of 150, got %f", flows)
+	}
+}
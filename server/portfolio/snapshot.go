@@ -0,0 +1,256 @@
+package portfolio
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// SnapshotRow is the stored valuation of one instrument on one day
+type SnapshotRow struct {
+	Symbol         string
+	Currency       string
+	Quantity       float64
+	Cost           float64
+	MarketValue    *float64
+	CostNZD        *float64
+	MarketValueNZD *float64
+}
+
+// HistoryPoint is the portfolio total on one day
+type HistoryPoint struct {
+	Date              string  `json:"date"`
+	MarketValueNZD    float64 `json:"marketValueNzd"`
+	CostNZD           float64 `json:"costNzd"`
+	UnrealisedGainNZD float64 `json:"unrealisedGainNzd"`
+	Complete          bool    `json:"complete"`
+}
+
+// Intervals supported by Resample
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+// SnapshotRows folds a valuation into one row per symbol and currency. A value
+// stays nil if any holding contributing to it was missing a price or rate.
+func SnapshotRows(v Valuation) []SnapshotRow {
+	type key struct{ symbol, currency string }
+	rows := map[key]*SnapshotRow{}
+	order := []key{}
+
+	for _, h := range v.Holdings {
+		k := key{h.Symbol, h.Currency}
+		row, ok := rows[k]
+		if !ok {
+			row = &SnapshotRow{
+				Symbol:         h.Symbol,
+				Currency:       h.Currency,
+				MarketValue:    float64Ptr(0),
+				CostNZD:        float64Ptr(0),
+				MarketValueNZD: float64Ptr(0),
+			}
+			rows[k] = row
+			order = append(order, k)
+		}
+		row.Quantity += h.Quantity
+		row.Cost += h.Cost
+		row.MarketValue = addPtr(row.MarketValue, h.MarketValue)
+		row.CostNZD = addPtr(row.CostNZD, h.CostNZD)
+		row.MarketValueNZD = addPtr(row.MarketValueNZD, h.MarketValueNZD)
+	}
+
+	result := make([]SnapshotRow, 0, len(order))
+	for _, k := range order {
+		result = append(result, *rows[k])
+	}
+	return result
+}
+
+// SaveSnapshot replaces the user's snapshot for the valuation date
+func SaveSnapshot(ctx context.Context, db *sql.DB, userID string, v Valuation) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := SaveSnapshotTx(ctx, tx, userID, v); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit snapshot: %w", err)
+	}
+	return nil
+}
+
+// SaveSnapshotTx is SaveSnapshot within the caller's transaction
+func SaveSnapshotTx(ctx context.Context, tx *sql.Tx, userID string, v Valuation) error {
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM portfolio_snapshots
+		WHERE user_id = $1 AND snapshot_date = $2
+	`, userID, v.Date); err != nil {
+		return fmt.Errorf("failed to clear snapshot: %w", err)
+	}
+
+	for _, row := range SnapshotRows(v) {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO portfolio_snapshots
+				(user_id, snapshot_date, symbol, currency, quantity, cost, market_value, cost_nzd, market_value_nzd)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, userID, v.Date, row.Symbol, row.Currency, row.Quantity, row.Cost,
+			row.MarketValue, row.CostNZD, row.MarketValueNZD); err != nil {
+			return fmt.Errorf("failed to insert snapshot row: %w", err)
+		}
+	}
+	return nil
+}
+
+// Snapshot values the user's portfolio on date and stores the result
+func Snapshot(ctx context.Context, db *sql.DB, userID string, date time.Time) error {
+	v, err := Load(ctx, db, userID, date)
+	if err != nil {
+		return err
+	}
+	return SaveSnapshot(ctx, db, userID, v)
+}
+
+// Backfill snapshots every day from from to to inclusive using stored prices.
+// There is no transaction ledger, so past days are valued with current holdings.
+func Backfill(ctx context.Context, db *sql.DB, userID string, from, to time.Time) (int, error) {
+	count := 0
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		if err := Snapshot(ctx, db, userID, d); err != nil {
+			return count, fmt.Errorf("failed to snapshot %s: %w", d.Format(DateLayout), err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// SnapshotAll records a snapshot on date for every user with holdings. Failures
+// for individual users are logged and counted rather than stopping the run.
+func SnapshotAll(ctx context.Context, db *sql.DB, date time.Time) (int, error) {
+	userIDs, err := holdingUserIDs(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	failed := 0
+	for _, userID := range userIDs {
+		if err := Snapshot(ctx, db, userID, date); err != nil {
+			slog.ErrorContext(ctx, "Error snapshotting portfolio", "uid", userID, "error", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return len(userIDs) - failed, fmt.Errorf("%d of %d snapshots failed", failed, len(userIDs))
+	}
+	return len(userIDs), nil
+}
+
+// LoadHistory returns daily portfolio totals between from and to inclusive
+func LoadHistory(ctx context.Context, db *sql.DB, userID string, from, to time.Time) ([]HistoryPoint, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT snapshot_date,
+			COALESCE(SUM(market_value_nzd), 0),
+			COALESCE(SUM(cost_nzd), 0),
+			BOOL_AND(market_value_nzd IS NOT NULL)
+		FROM portfolio_snapshots
+		WHERE user_id = $1 AND snapshot_date BETWEEN $2 AND $3
+		GROUP BY snapshot_date
+		ORDER BY snapshot_date
+	`, userID, from.Format(DateLayout), to.Format(DateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	points := []HistoryPoint{}
+	for rows.Next() {
+		var p HistoryPoint
+		var date time.Time
+		if err := rows.Scan(&date, &p.MarketValueNZD, &p.CostNZD, &p.Complete); err != nil {
+			return nil, fmt.Errorf("failed to scan snapshot: %w", err)
+		}
+		p.Date = date.Format(DateLayout)
+		p.UnrealisedGainNZD = p.MarketValueNZD - p.CostNZD
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate snapshots: %w", err)
+	}
+	return points, nil
+}
+
+// Resample keeps the last point in each week (starting Monday) or calendar month.
+// Points must be sorted by date.
+func Resample(points []HistoryPoint, interval string) ([]HistoryPoint, error) {
+	var bucket func(time.Time) string
+	switch interval {
+	case IntervalDay, "":
+		return points, nil
+	case IntervalWeek:
+		bucket = func(d time.Time) string {
+			year, week := d.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}
+	case IntervalMonth:
+		bucket = func(d time.Time) string { return d.Format("2006-01") }
+	default:
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+
+	result := []HistoryPoint{}
+	lastBucket := ""
+	for _, p := range points {
+		d, err := time.Parse(DateLayout, p.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid point date %q: %w", p.Date, err)
+		}
+		b := bucket(d)
+		if b == lastBucket {
+			result[len(result)-1] = p
+		} else {
+			result = append(result, p)
+			lastBucket = b
+		}
+	}
+	return result, nil
+}
+
+func holdingUserIDs(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT DISTINCT user_id FROM holdings ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holding users: %w", err)
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan user id: %w", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate holding users: %w", err)
+	}
+	return userIDs, nil
+}
+
+// addPtr adds b to a, returning nil if either is nil
+func addPtr(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	return float64Ptr(*a + *b)
+}
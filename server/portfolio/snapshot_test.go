@@ -0,0 +1,80 @@
+package portfolio
+
+import (
+	"testing"
+)
+
+func TestSnapshotRows_AggregatesBySymbol(t *testing.T) {
+	date := mustDate(t, "2025-03-31")
+	holdings := []Holding{
+		{Name: "Apple Inc.", Symbol: "AAPL", Quantity: 10, Currency: "USD", Cost: 1500},
+		{Name: "Apple Inc.", Symbol: "AAPL", Quantity: 5, Currency: "USD", Cost: 900},
+		{Name: "Unpriced", Symbol: "NOPE", Quantity: 1, Currency: "USD", Cost: 100},
+	}
+	prices := map[string]Quote{"AAPL": {Value: 200, Date: date}}
+	rates := map[string]Quote{"USD": {Value: 0.5, Date: date}}
+
+	rows := SnapshotRows(Value(date, holdings, prices, rates))
+
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	aapl := rows[0]
+	if aapl.Symbol != "AAPL" || !approxEqual(aapl.Quantity, 15) || !approxEqual(aapl.Cost, 2400) {
+		t.Errorf("Expected AAPL quantity 15 and cost 2400, got %+v", aapl)
+	}
+	if aapl.MarketValueNZD == nil || !approxEqual(*aapl.MarketValueNZD, 6000) {
+		t.Errorf("Expected AAPL NZD market value 6000, got %v", aapl.MarketValueNZD)
+	}
+
+	nope := rows[1]
+	if nope.MarketValue != nil || nope.MarketValueNZD != nil {
+		t.Error("Expected nil market values for unpriced holding")
+	}
+	if nope.CostNZD == nil || !approxEqual(*nope.CostNZD, 200) {
+		t.Errorf("Expected cost NZD 200, got %v", nope.CostNZD)
+	}
+}
+
+func TestResample(t *testing.T) {
+	points := []HistoryPoint{
+		{Date: "2025-03-28", MarketValueNZD: 1}, // Friday
+		{Date: "2025-03-30", MarketValueNZD: 2}, // Sunday, same ISO week
+		{Date: "2025-03-31", MarketValueNZD: 3}, // Monday, new week
+		{Date: "2025-04-01", MarketValueNZD: 4},
+	}
+
+	testCases := []struct {
+		name     string
+		interval string
+		expected []string
+	}{
+		{name: "Day", interval: IntervalDay, expected: []string{"2025-03-28", "2025-03-30", "2025-03-31", "2025-04-01"}},
+		{name: "Week", interval: IntervalWeek, expected: []string{"2025-03-30", "2025-04-01"}},
+		{name: "Month", interval: IntervalMonth, expected: []string{"2025-03-31", "2025-04-01"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := Resample(points, tc.interval)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(result) != len(tc.expected) {
+				t.Fatalf("Expected %d points, got %d", len(tc.expected), len(result))
+			}
+			for i, p := range result {
+				if p.Date != tc.expected[i] {
+					t.Errorf("Point %d: expected %s, got %s", i, tc.expected[i], p.Date)
+				}
+			}
+		})
+	}
+}
+
+func TestResample_UnknownInterval(t *testing.T) {
+	if _, err := Resample(nil, "hour"); err == nil {
+		t.Error("Expected error for unknown interval")
+	}
+}
@@ -0,0 +1,124 @@
+package portfolio
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// LoadHoldings returns all holdings for a user
+func LoadHoldings(ctx context.Context, db *sql.DB, userID string) ([]Holding, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, symbol, quantity, currency, cost, cost_nzd
+		FROM holdings
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	holdings := []Holding{}
+	for rows.Next() {
+		var h Holding
+		var costNZD sql.NullFloat64
+		if err := rows.Scan(&h.Name, &h.Symbol, &h.Quantity, &h.Currency, &h.Cost, &costNZD); err != nil {
+			return nil, fmt.Errorf("failed to scan holding: %w", err)
+		}
+		h.CostNZD = nullFloat64Ptr(costNZD)
+		holdings = append(holdings, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate holdings: %w", err)
+	}
+	return holdings, nil
+}
+
+// LoadPrices returns the latest closing price on or before date for each symbol
+func LoadPrices(ctx context.Context, db *sql.DB, symbols []string, date time.Time) (map[string]Quote, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT ON (symbol) symbol, price_date, close
+		FROM prices
+		WHERE symbol = ANY($1) AND price_date <= $2
+		ORDER BY symbol, price_date DESC
+	`, pq.Array(symbols), date.Format(DateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prices: %w", err)
+	}
+	return scanQuotes(rows)
+}
+
+// LoadRates returns the latest FX rate on or before date for each currency
+func LoadRates(ctx context.Context, db *sql.DB, currencies []string, date time.Time) (map[string]Quote, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT DISTINCT ON (currency) currency, rate_date, rate
+		FROM fx_rates
+		WHERE currency = ANY($1) AND rate_date <= $2
+		ORDER BY currency, rate_date DESC
+	`, pq.Array(currencies), date.Format(DateLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fx rates: %w", err)
+	}
+	return scanQuotes(rows)
+}
+
+// Load fetches a user's holdings with the prices and rates needed to value them
+func Load(ctx context.Context, db *sql.DB, userID string, date time.Time) (Valuation, error) {
+	holdings, err := LoadHoldings(ctx, db, userID)
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	symbols, currencies := keys(holdings)
+
+	prices, err := LoadPrices(ctx, db, symbols, date)
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	rates, err := LoadRates(ctx, db, currencies, date)
+	if err != nil {
+		return Valuation{}, err
+	}
+
+	return Value(date, holdings, prices, rates), nil
+}
+
+func scanQuotes(rows *sql.Rows) (map[string]Quote, error) {
+	defer rows.Close()
+
+	quotes := map[string]Quote{}
+	for rows.Next() {
+		var key string
+		var q Quote
+		if err := rows.Scan(&key, &q.Date, &q.Value); err != nil {
+			return nil, fmt.Errorf("failed to scan quote: %w", err)
+		}
+		quotes[key] = q
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate quotes: %w", err)
+	}
+	return quotes, nil
+}
+
+// keys returns the distinct symbols and currencies across holdings
+func keys(holdings []Holding) (symbols []string, currencies []string) {
+	seenSymbol := map[string]bool{}
+	seenCurrency := map[string]bool{}
+	for _, h := range holdings {
+		if !seenSymbol[h.Symbol] {
+			seenSymbol[h.Symbol] = true
+			symbols = append(symbols, h.Symbol)
+		}
+		if !seenCurrency[h.Currency] {
+			seenCurrency[h.Currency] = true
+			currencies = append(currencies, h.Currency)
+		}
+	}
+	return symbols, currencies
+}
@@ -0,0 +1,178 @@
+package portfolio
+
+import (
+	"fif/metrics"
+	"sort"
+	"time"
+)
+
+// DateLayout is the format used for dates in queries and responses
+const DateLayout = "2006-01-02"
+
+// BaseCurrency is the currency totals are reported in
+const BaseCurrency = "NZD"
+
+// Holding is a position as stored in the holdings table
+type Holding struct {
+	Name     string
+	Symbol   string
+	Quantity float64
+	Currency string
+	Cost     float64
+	// CostNZD is Cost at the exchange rate when it was paid, nil when that
+	// rate is not known
+	CostNZD *float64
+}
+
+// Quote is a dated value, either a closing price or an FX rate
+type Quote struct {
+	Value float64
+	Date  time.Time
+}
+
+// HoldingValuation is the market value of a single holding on the valuation date.
+// Fields that cannot be computed because a price or rate is missing are nil.
+type HoldingValuation struct {
+	Name              string   `json:"name"`
+	Symbol            string   `json:"symbol"`
+	Currency          string   `json:"currency"`
+	Quantity          float64  `json:"quantity"`
+	Cost              float64  `json:"cost"`
+	Price             *float64 `json:"price"`
+	PriceDate         string   `json:"priceDate,omitempty"`
+	FXRate            *float64 `json:"fxRate"`
+	FXRateDate        string   `json:"fxRateDate,omitempty"`
+	MarketValue       *float64 `json:"marketValue"`
+	UnrealisedGain    *float64 `json:"unrealisedGain"`
+	MarketValueNZD    *float64 `json:"marketValueNzd"`
+	CostNZD           *float64 `json:"costNzd"`
+	UnrealisedGainNZD *float64 `json:"unrealisedGainNzd"`
+	Allocation        *float64 `json:"allocation"`
+	MissingPrice      bool     `json:"missingPrice"`
+	MissingRate       bool     `json:"missingRate"`
+	// CostNZDAtCurrentRate is true when the rate at purchase is not known, so
+	// CostNZD uses the valuation date rate and leaves out the FX move
+	CostNZDAtCurrentRate bool `json:"costNzdAtCurrentRate"`
+}
+
+// CurrencyTotal sums the priced holdings held in one currency
+type CurrencyTotal struct {
+	Currency       string  `json:"currency"`
+	MarketValue    float64 `json:"marketValue"`
+	Cost           float64 `json:"cost"`
+	UnrealisedGain float64 `json:"unrealisedGain"`
+}
+
+// Valuation is the market value of a user's portfolio on a given date.
+// NZD totals only include holdings with both a price and an FX rate; Complete
+// is false when any holding had to be left out.
+type Valuation struct {
+	Date              string             `json:"date"`
+	Holdings          []HoldingValuation `json:"holdings"`
+	Totals            []CurrencyTotal    `json:"totals"`
+	MarketValueNZD    float64            `json:"marketValueNzd"`
+	CostNZD           float64            `json:"costNzd"`
+	UnrealisedGainNZD float64            `json:"unrealisedGainNzd"`
+	Complete          bool               `json:"complete"`
+}
+
+// Value computes a valuation from holdings and the latest price per symbol and
+// rate per currency on or before date. Rates are units of currency per 1 NZD.
+// NZD cost is the holding's CostNZD, fixed when it was paid, so NZD gains
+// include currency moves; without one the valuation date rate is used.
+func Value(date time.Time, holdings []Holding, prices map[string]Quote, rates map[string]Quote) Valuation {
+	defer metrics.ObserveCalculation("valuation", time.Now())
+
+	v := Valuation{
+		Date:     date.Format(DateLayout),
+		Holdings: make([]HoldingValuation, 0, len(holdings)),
+		Totals:   []CurrencyTotal{},
+		Complete: true,
+	}
+	totals := map[string]*CurrencyTotal{}
+
+	for _, h := range holdings {
+		hv := HoldingValuation{
+			Name:     h.Name,
+			Symbol:   h.Symbol,
+			Currency: h.Currency,
+			Quantity: h.Quantity,
+			Cost:     h.Cost,
+		}
+
+		if p, ok := prices[h.Symbol]; ok {
+			hv.Price = float64Ptr(p.Value)
+			hv.PriceDate = p.Date.Format(DateLayout)
+			hv.MarketValue = float64Ptr(h.Quantity * p.Value)
+			hv.UnrealisedGain = float64Ptr(*hv.MarketValue - h.Cost)
+
+			t, ok := totals[h.Currency]
+			if !ok {
+				t = &CurrencyTotal{Currency: h.Currency}
+				totals[h.Currency] = t
+			}
+			t.MarketValue += *hv.MarketValue
+			t.Cost += h.Cost
+			t.UnrealisedGain += *hv.UnrealisedGain
+		} else {
+			hv.MissingPrice = true
+		}
+
+		// Fixed when it was paid, so it does not need today's rate
+		if h.CostNZD != nil {
+			hv.CostNZD = float64Ptr(*h.CostNZD)
+		}
+
+		if r, ok := rateFor(h.Currency, date, rates); ok {
+			hv.FXRate = float64Ptr(r.Value)
+			hv.FXRateDate = r.Date.Format(DateLayout)
+			if hv.CostNZD == nil {
+				hv.CostNZD = float64Ptr(h.Cost / r.Value)
+				hv.CostNZDAtCurrentRate = h.Currency != BaseCurrency
+			}
+			if hv.MarketValue != nil {
+				hv.MarketValueNZD = float64Ptr(*hv.MarketValue / r.Value)
+				hv.UnrealisedGainNZD = float64Ptr(*hv.MarketValueNZD - *hv.CostNZD)
+			}
+		} else {
+			hv.MissingRate = true
+		}
+
+		if hv.MarketValueNZD != nil {
+			v.MarketValueNZD += *hv.MarketValueNZD
+			v.CostNZD += *hv.CostNZD
+			v.UnrealisedGainNZD += *hv.UnrealisedGainNZD
+		} else {
+			v.Complete = false
+		}
+
+		v.Holdings = append(v.Holdings, hv)
+	}
+
+	for i := range v.Holdings {
+		hv := &v.Holdings[i]
+		if hv.MarketValueNZD != nil && v.MarketValueNZD > 0 {
+			hv.Allocation = float64Ptr(*hv.MarketValueNZD / v.MarketValueNZD * 100)
+		}
+	}
+
+	for _, t := range totals {
+		v.Totals = append(v.Totals, *t)
+	}
+	sort.Slice(v.Totals, func(i, j int) bool { return v.Totals[i].Currency < v.Totals[j].Currency })
+
+	return v
+}
+
+// rateFor looks up the rate for currency, treating NZD as always 1
+func rateFor(currency string, date time.Time, rates map[string]Quote) (Quote, bool) {
+	if currency == BaseCurrency {
+		return Quote{Value: 1, Date: date}, true
+	}
+	r, ok := rates[currency]
+	return r, ok
+}
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
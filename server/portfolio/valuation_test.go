@@ -0,0 +1,192 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func mustDate(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse(DateLayout, s)
+	if err != nil {
+		t.Fatalf("Failed to parse date %q: %v", s, err)
+	}
+	return d
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestValue_ConvertsToNZD(t *testing.T) {
+	date := mustDate(t, "2025-03-31")
+	holdings := []Holding{
+		{Name: "Apple Inc.", Symbol: "AAPL", Quantity: 10, Currency: "USD", Cost: 1500},
+		{Name: "Fisher & Paykel", Symbol: "FPH", Quantity: 100, Currency: "NZD", Cost: 3000},
+	}
+	prices := map[string]Quote{
+		"AAPL": {Value: 200, Date: mustDate(t, "2025-03-28")},
+		"FPH":  {Value: 35, Date: date},
+	}
+	rates := map[string]Quote{
+		"USD": {Value: 0.5, Date: date},
+	}
+
+	v := Value(date, holdings, prices, rates)
+
+	if !v.Complete {
+		t.Error("Expected valuation to be complete")
+	}
+
+	if v.Date != "2025-03-31" {
+		t.Errorf("Expected date 2025-03-31, got %s", v.Date)
+	}
+
+	// AAPL: 10 * 200 = 2000 USD = 4000 NZD, cost 1500 USD = 3000 NZD
+	aapl := v.Holdings[0]
+	if aapl.MarketValue == nil || !approxEqual(*aapl.MarketValue, 2000) {
+		t.Errorf("Expected AAPL market value 2000, got %v", aapl.MarketValue)
+	}
+	if aapl.MarketValueNZD == nil || !approxEqual(*aapl.MarketValueNZD, 4000) {
+		t.Errorf("Expected AAPL NZD market value 4000, got %v", aapl.MarketValueNZD)
+	}
+	if aapl.UnrealisedGainNZD == nil || !approxEqual(*aapl.UnrealisedGainNZD, 1000) {
+		t.Errorf("Expected AAPL NZD gain 1000, got %v", aapl.UnrealisedGainNZD)
+	}
+	if aapl.PriceDate != "2025-03-28" {
+		t.Errorf("Expected AAPL price date 2025-03-28, got %s", aapl.PriceDate)
+	}
+
+	// Totals: 4000 + 3500 = 7500 NZD, cost 3000 + 3000 = 6000 NZD
+	if !approxEqual(v.MarketValueNZD, 7500) {
+		t.Errorf("Expected NZD market value 7500, got %f", v.MarketValueNZD)
+	}
+	if !approxEqual(v.CostNZD, 6000) {
+		t.Errorf("Expected NZD cost 6000, got %f", v.CostNZD)
+	}
+	if !approxEqual(v.UnrealisedGainNZD, 1500) {
+		t.Errorf("Expected NZD gain 1500, got %f", v.UnrealisedGainNZD)
+	}
+
+	if aapl.Allocation == nil || !approxEqual(*aapl.Allocation, 4000.0/7500*100) {
+		t.Errorf("Expected AAPL allocation %f, got %v", 4000.0/7500*100, aapl.Allocation)
+	}
+
+	if len(v.Totals) != 2 || v.Totals[0].Currency != "NZD" || v.Totals[1].Currency != "USD" {
+		t.Fatalf("Expected NZD and USD totals, got %+v", v.Totals)
+	}
+	if !approxEqual(v.Totals[1].MarketValue, 2000) {
+		t.Errorf("Expected USD total 2000, got %f", v.Totals[1].MarketValue)
+	}
+}
+
+func TestValue_CostAtPurchaseRate(t *testing.T) {
+	date := mustDate(t, "2025-03-31")
+	// Bought for 1500 USD when 1 NZD bought 0.6 USD
+	costNZD := 2500.0
+	holdings := []Holding{
+		{Name: "Apple Inc.", Symbol: "AAPL", Quantity: 10, Currency: "USD", Cost: 1500, CostNZD: &costNZD},
+		{Name: "Vanguard", Symbol: "VTI", Quantity: 1, Currency: "USD", Cost: 250},
+	}
+	prices := map[string]Quote{
+		"AAPL": {Value: 150, Date: date},
+		"VTI":  {Value: 250, Date: date},
+	}
+	rates := map[string]Quote{"USD": {Value: 0.5, Date: date}}
+
+	v := Value(date, holdings, prices, rates)
+
+	// Assert the NZD gain includes the currency move: 3000 NZD now, 2500 paid
+	aapl := v.Holdings[0]
+	if aapl.CostNZD == nil || !approxEqual(*aapl.CostNZD, 2500) || aapl.CostNZDAtCurrentRate {
+		t.Errorf("Expected NZD cost 2500 from the purchase, got %v", aapl.CostNZD)
+	}
+	if aapl.UnrealisedGainNZD == nil || !approxEqual(*aapl.UnrealisedGainNZD, 500) {
+		t.Errorf("Expected NZD gain 500, got %v", aapl.UnrealisedGainNZD)
+	}
+
+	// Assert a holding without a purchase rate is flagged
+	if vti := v.Holdings[1]; !vti.CostNZDAtCurrentRate || vti.CostNZD == nil || !approxEqual(*vti.CostNZD, 500) {
+		t.Errorf("Expected NZD cost 500 at the current rate and flagged, got %+v", vti)
+	}
+	if !approxEqual(v.CostNZD, 3000) {
+		t.Errorf("Expected NZD cost 3000, got %f", v.CostNZD)
+	}
+}
+
+func TestValue_MissingPriceAndRate(t *testing.T) {
+	date := mustDate(t, "2025-03-31")
+	holdings := []Holding{
+		{Name: "Unpriced", Symbol: "NOPE", Quantity: 1, Currency: "USD", Cost: 100},
+		{Name: "No rate", Symbol: "VOD", Quantity: 10, Currency: "GBP", Cost: 50},
+	}
+	prices := map[string]Quote{
+		"VOD": {Value: 7, Date: date},
+	}
+	rates := map[string]Quote{
+		"USD": {Value: 0.5, Date: date},
+	}
+
+	v := Value(date, holdings, prices, rates)
+
+	if v.Complete {
+		t.Error("Expected valuation to be incomplete")
+	}
+
+	unpriced := v.Holdings[0]
+	if !unpriced.MissingPrice || unpriced.MissingRate {
+		t.Errorf("Expected only missing price, got %+v", unpriced)
+	}
+	if unpriced.MarketValue != nil || unpriced.Allocation != nil {
+		t.Error("Expected no market value or allocation for unpriced holding")
+	}
+	if unpriced.CostNZD == nil || !approxEqual(*unpriced.CostNZD, 200) {
+		t.Errorf("Expected cost NZD 200, got %v", unpriced.CostNZD)
+	}
+
+	noRate := v.Holdings[1]
+	if noRate.MissingPrice || !noRate.MissingRate {
+		t.Errorf("Expected only missing rate, got %+v", noRate)
+	}
+	if noRate.MarketValue == nil || !approxEqual(*noRate.MarketValue, 70) {
+		t.Errorf("Expected GBP market value 70, got %v", noRate.MarketValue)
+	}
+	if noRate.MarketValueNZD != nil {
+		t.Error("Expected no NZD market value without a rate")
+	}
+
+	if v.MarketValueNZD != 0 {
+		t.Errorf("Expected NZD total 0, got %f", v.MarketValueNZD)
+	}
+}
+
+func TestValue_PurchaseCostWithoutRate(t *testing.T) {
+	date := mustDate(t, "2025-03-31")
+	costNZD := 80.0
+	holdings := []Holding{
+		{Name: "No rate", Symbol: "VOD", Quantity: 10, Currency: "GBP", Cost: 50, CostNZD: &costNZD},
+	}
+
+	v := Value(date, holdings, map[string]Quote{"VOD": {Value: 7, Date: date}}, map[string]Quote{})
+
+	// Assert the cost paid is kept even though today's rate is missing
+	h := v.Holdings[0]
+	if !h.MissingRate || h.CostNZD == nil || !approxEqual(*h.CostNZD, 80) {
+		t.Errorf("Expected NZD cost 80 without a rate, got %+v", h)
+	}
+	if h.MarketValueNZD != nil || h.UnrealisedGainNZD != nil {
+		t.Error("Expected no NZD value or gain without a rate")
+	}
+}
+
+func TestValue_NoHoldings(t *testing.T) {
+	v := Value(mustDate(t, "2025-03-31"), nil, nil, nil)
+
+	if !v.Complete {
+		t.Error("Expected empty valuation to be complete")
+	}
+	if v.Holdings == nil || v.Totals == nil {
+		t.Error("Expected empty slices rather than nil so they encode as []")
+	}
+}
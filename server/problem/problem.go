@@ -0,0 +1,111 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// ContentType is the media type for problem details (RFC 7807)
+const ContentType = "application/problem+json"
+
+// Stable, machine-readable error codes returned in the "code" member
+const (
+	CodeUnauthorized     = "unauthorized"
+	CodeTokenExpired     = "token_expired"
+	CodeTokenInvalid     = "token_invalid"
+	CodeTokenRevoked     = "token_revoked"
+	CodeAuthUnavailable  = "auth_unavailable"
+	CodeForbidden        = "forbidden"
+	CodeValidationFailed = "validation_failed"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "unavailable"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+)
+
+// FieldError describes why one input field was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details body extended with a stable code,
+// the request ID and any field-level validation errors
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// New builds a Problem for the request
+func New(r *http.Request, status int, code, detail string) Problem {
+	return Problem{
+		Type:      "/problems/" + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: chimiddleware.GetReqID(r.Context()),
+	}
+}
+
+// Write sends a Problem as the response
+func (p Problem) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		slog.Error("Error writing problem response", "request_id", p.RequestID, "error", err)
+	}
+}
+
+// Write sends a problem response with the given status, code and detail
+func Write(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	New(r, status, code, detail).Write(w)
+}
+
+// Unauthorized sends a 401 with code unauthorized
+func Unauthorized(w http.ResponseWriter, r *http.Request) {
+	Write(w, r, http.StatusUnauthorized, CodeUnauthorized, "authentication is required")
+}
+
+// NotFound sends a 404 with code not_found
+func NotFound(w http.ResponseWriter, r *http.Request, detail string) {
+	Write(w, r, http.StatusNotFound, CodeNotFound, detail)
+}
+
+// Conflict sends a 409 with code conflict
+func Conflict(w http.ResponseWriter, r *http.Request, detail string) {
+	Write(w, r, http.StatusConflict, CodeConflict, detail)
+}
+
+// Validation sends a 400 with code validation_failed listing the rejected fields
+func Validation(w http.ResponseWriter, r *http.Request, errs ...FieldError) {
+	p := New(r, http.StatusBadRequest, CodeValidationFailed, "the request has invalid fields")
+	p.Errors = errs
+	p.Write(w)
+}
+
+// Internal logs err with the request ID and sends a 500 that reveals nothing
+// about the cause. If the request ran out of time it sends a 503 instead.
+func Internal(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	p := New(r, http.StatusInternalServerError, CodeInternal, "")
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		p = New(r, http.StatusServiceUnavailable, CodeTimeout, "the request took too long")
+	}
+	slog.ErrorContext(r.Context(), msg, "error", err)
+	p.Write(w)
+}
@@ -0,0 +1,103 @@
+package problem
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+func TestValidation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/portfolio/history?from=bad", nil)
+	w := httptest.NewRecorder()
+
+	Validation(w, req, FieldError{Field: "from", Message: "must be a date"})
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	if contentType := w.Header().Get("Content-Type"); contentType != ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", ContentType, contentType)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if p.Code != CodeValidationFailed {
+		t.Errorf("Expected code %s, got %s", CodeValidationFailed, p.Code)
+	}
+
+	if p.Title != "Bad Request" || p.Status != http.StatusBadRequest {
+		t.Errorf("Expected title Bad Request and status 400, got %q and %d", p.Title, p.Status)
+	}
+
+	if p.Instance != "/api/portfolio/history" {
+		t.Errorf("Expected instance /api/portfolio/history, got %s", p.Instance)
+	}
+
+	if len(p.Errors) != 1 || p.Errors[0].Field != "from" {
+		t.Errorf("Expected one field error for from, got %+v", p.Errors)
+	}
+}
+
+func TestInternal_IncludesRequestIDAndHidesError(t *testing.T) {
+	handler := chimiddleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Internal(w, r, "Error doing something", errors.New("secret database detail"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/holdings", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if p.Code != CodeInternal {
+		t.Errorf("Expected code %s, got %s", CodeInternal, p.Code)
+	}
+
+	if p.RequestID == "" {
+		t.Error("Expected a request ID")
+	}
+
+	if p.Detail != "" {
+		t.Errorf("Expected no detail, got %q", p.Detail)
+	}
+}
+
+func TestInternal_Timeout(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Internal(w, r, "Error querying holdings", fmt.Errorf("query failed: %w", context.DeadlineExceeded))
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/holdings", nil))
+
+	// Assert a timed out request is reported as unavailable, not as a bug
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+
+	var p Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if p.Code != CodeTimeout {
+		t.Errorf("Expected code %s, got %s", CodeTimeout, p.Code)
+	}
+}
@@ -0,0 +1,32 @@
+// Package problemtest checks problem responses in tests
+package problemtest
+
+import (
+	"encoding/json"
+	"fif/problem"
+	"net/http/httptest"
+	"testing"
+)
+
+// Assert checks that w holds a problem response with expectedCode whose
+// status matches the response status
+func Assert(t testing.TB, w *httptest.ResponseRecorder, expectedCode string) {
+	t.Helper()
+
+	if contentType := w.Header().Get("Content-Type"); contentType != problem.ContentType {
+		t.Errorf("Expected Content-Type %s, got %s", problem.ContentType, contentType)
+	}
+
+	var p problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+		t.Fatalf("Failed to decode problem: %v", err)
+	}
+
+	if p.Code != expectedCode {
+		t.Errorf("Expected code %q, got %q", expectedCode, p.Code)
+	}
+
+	if p.Status != w.Code {
+		t.Errorf("Expected problem status %d to match response status %d", p.Status, w.Code)
+	}
+}
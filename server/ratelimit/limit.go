@@ -0,0 +1,95 @@
+// Package ratelimit throttles requests with token buckets keyed by user or
+// client IP.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests per Period, refilling continuously, with bursts of up
+// to Requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Off reports whether the limit disables rate limiting
+func (l Limit) Off() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// rate is the number of tokens added per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d per %s", l.Requests, l.Period)
+}
+
+// ParseLimit reads a limit such as "100/m", "10/s" or "1000/h". "off"
+// disables rate limiting.
+func ParseLimit(s string) (Limit, error) {
+	if s == "off" {
+		return Limit{}, nil
+	}
+
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must look like 100/m", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("rate limit %q must start with a positive number", s)
+	}
+
+	periods := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+	period, ok := periods[unit]
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must be per s, m or h", s)
+	}
+	return Limit{Requests: n, Period: period}, nil
+}
+
+// Result is the outcome of taking a token
+type Result struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, when not Allowed
+	RetryAfter time.Duration
+}
+
+// Store holds buckets. Take removes a token from the bucket for key if one is
+// available.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// take applies a refill of elapsed to a bucket holding tokens, then tries to
+// remove one. It returns the new token count and the result.
+func take(tokens float64, elapsed time.Duration, limit Limit) (float64, Result) {
+	burst := float64(limit.Requests)
+	tokens = math.Min(burst, tokens+elapsed.Seconds()*limit.rate())
+
+	res := Result{}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - tokens) / limit.rate())
+	}
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = secondsToDuration((burst - tokens) / limit.rate())
+	return tokens, res
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	testCases := []struct {
+		spec      string
+		expected  Limit
+		expectErr bool
+	}{
+		{spec: "100/m", expected: Limit{Requests: 100, Period: time.Minute}},
+		{spec: "5/s", expected: Limit{Requests: 5, Period: time.Second}},
+		{spec: "off", expected: Limit{}},
+		{spec: "100", expectErr: true},
+		{spec: "0/m", expectErr: true},
+		{spec: "10/d", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.spec, func(t *testing.T) {
+			limit, err := ParseLimit(tc.spec)
+			if tc.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error for %q", tc.spec)
+				}
+				return
+			}
+			if err != nil || limit != tc.expected {
+				t.Errorf("Expected %+v, got %+v (%v)", tc.expected, limit, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	// Create a store with a controllable clock and a 2 per second limit
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return clock }
+	limit := Limit{Requests: 2, Period: time.Second}
+	ctx := context.Background()
+
+	// Assert the burst is allowed and the next request is refused
+	for i := 0; i < 2; i++ {
+		if res, _ := store.Take(ctx, "k", limit); !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	res, _ := store.Take(ctx, "k", limit)
+	if res.Allowed {
+		t.Fatal("Expected the third request to be refused")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected retry after 500ms, got %v", res.RetryAfter)
+	}
+
+	// Assert other keys have their own bucket
+	if res, _ := store.Take(ctx, "other", limit); !res.Allowed || res.Remaining != 1 {
+		t.Errorf("Expected a fresh bucket for another key, got %+v", res)
+	}
+
+	// Assert a token comes back after it refills
+	clock = clock.Add(500 * time.Millisecond)
+	if res, _ := store.Take(ctx, "k", limit); !res.Allowed {
+		t.Error("Expected a request to be allowed after refilling")
+	}
+}
+
+func TestMemoryStore_SweepsFullBuckets(t *testing.T) {
+	// Create a bucket, then let it refill and a sweep come due
+	clock := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return clock }
+	limit := Limit{Requests: 10, Period: time.Minute}
+
+	store.Take(context.Background(), "idle", limit)
+	clock = clock.Add(sweepInterval + time.Minute)
+	store.Take(context.Background(), "busy", limit)
+
+	// Assert only the active bucket is kept
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("Expected the idle bucket to be swept")
+	}
+	if len(store.buckets) != 1 {
+		t.Errorf("Expected 1 bucket, got %d", len(store.buckets))
+	}
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"fif/middleware"
+	"fif/problem"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// Middleware limits requests in the named group to limit per user, or per
+// client IP when the request is not authenticated. Place it after
+// AuthMiddleware to key by user. If the store fails the request is let
+// through rather than taking the API down with it.
+func Middleware(store Store, group string, limit Limit) func(handler http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Off() {
+			return next
+		}
+
+		policy := fmt.Sprintf("%d;w=%d", limit.Requests, int(limit.Period.Seconds()))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := store.Take(r.Context(), group+":"+clientKey(r), limit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Rate limit store failed, allowing request", "group", group, "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				problem.Write(w, r, http.StatusTooManyRequests, problem.CodeRateLimited,
+					fmt.Sprintf("rate limit of %s exceeded", limit))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientKey identifies who a request counts against
+func clientKey(r *http.Request) string {
+	// Demo visitors share one user, so they are limited by address instead
+	if token, ok := r.Context().Value(middleware.CtxTokenKey{}).(*auth.Token); ok && token != nil && !middleware.IsDemo(token) {
+		return "uid:" + token.UID
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
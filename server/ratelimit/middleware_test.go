@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fif/middleware"
+	"fif/problem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/auth"
+)
+
+// failingStore is a Store whose backend is down
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	return Result{}, errors.New("db down")
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestMiddleware_LimitsAndSetsHeaders(t *testing.T) {
+	// Create a limiter allowing one request per minute
+	handler := Middleware(NewMemoryStore(), "api", Limit{Requests: 1, Period: time.Minute})(okHandler())
+
+	request := func(uid string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/holdings", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.CtxTokenKey{}, &auth.Token{UID: uid}))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Assert the first request passes with headers describing the limit
+	w := request("u1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "1;w=60" {
+		t.Errorf("Expected RateLimit-Policy 1;w=60, got %q", got)
+	}
+
+	// Assert the second request from the same user is refused
+	w = request("u1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", got)
+	}
+
+	var body problem.Problem
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body.Code != problem.CodeRateLimited {
+		t.Errorf("Expected code %s, got %s", problem.CodeRateLimited, body.Code)
+	}
+
+	// Assert another user is unaffected
+	if w := request("u2"); w.Code != http.StatusOK {
+		t.Errorf("Expected another user to be allowed, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_KeysAnonymousRequestsByIP(t *testing.T) {
+	// Create two anonymous requests from the same address on different ports
+	a := httptest.NewRequest(http.MethodGet, "/api/holdings", nil)
+	a.RemoteAddr = "203.0.113.7:1234"
+	b := httptest.NewRequest(http.MethodGet, "/api/holdings", nil)
+	b.RemoteAddr = "203.0.113.7:5678"
+
+	// Assert they count against the same bucket
+	if clientKey(a) != "ip:203.0.113.7" || clientKey(a) != clientKey(b) {
+		t.Errorf("Expected both to be keyed by IP, got %q and %q", clientKey(a), clientKey(b))
+	}
+}
+
+func TestMiddleware_FailsOpen(t *testing.T) {
+	// Create a limiter whose store is unavailable
+	handler := Middleware(failingStore{}, "api", Limit{Requests: 1, Period: time.Minute})(okHandler())
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/holdings", nil))
+
+	// Assert the request is let through
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
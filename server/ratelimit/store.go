@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets idle buckets
+const sweepInterval = 10 * time.Minute
+
+// MemoryStore keeps buckets in this process. Each replica limits separately.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled, after which it can be forgotten
+	full time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		s.buckets[key] = b
+	}
+
+	tokens, res := take(b.tokens, now.Sub(b.updated), limit)
+	b.tokens, b.updated, b.full = tokens, now, now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep drops buckets that have refilled, since a new bucket is the same.
+// It must be called with mu held.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// PostgresStore keeps buckets in the rate_limits table so replicas share them
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a Store backed by db
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to begin rate limit transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limits (key, tokens, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (key) DO NOTHING
+	`, key, limit.Requests); err != nil {
+		return Result{}, fmt.Errorf("failed to create rate limit bucket: %w", err)
+	}
+
+	// The database clock is used so replicas agree on elapsed time
+	var tokens, elapsed float64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT tokens, GREATEST(EXTRACT(EPOCH FROM NOW() - updated_at), 0)
+		FROM rate_limits
+		WHERE key = $1
+		FOR UPDATE
+	`, key).Scan(&tokens, &elapsed); err != nil {
+		return Result{}, fmt.Errorf("failed to read rate limit bucket: %w", err)
+	}
+
+	tokens, res := take(tokens, secondsToDuration(elapsed), limit)
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE rate_limits SET tokens = $2, updated_at = NOW() WHERE key = $1
+	`, key, tokens); err != nil {
+		return Result{}, fmt.Errorf("failed to update rate limit bucket: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("failed to commit rate limit bucket: %w", err)
+	}
+	return res, nil
+}
+
+// Cleanup deletes buckets untouched for longer than idle
+func (s *PostgresStore) Cleanup(ctx context.Context, idle time.Duration) error {
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM rate_limits WHERE updated_at < NOW() - make_interval(secs => $1)
+	`, idle.Seconds()); err != nil {
+		return fmt.Errorf("failed to clean up rate limits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"database/sql"
+	"fif/config"
+	"fif/ratelimit"
+	"net/http"
+)
+
+// rateLimits holds the store and the limit of each route group
+type rateLimits struct {
+	store  ratelimit.Store
+	limits map[string]ratelimit.Limit
+}
+
+// newRateLimits creates the configured store. The postgres store shares
+// buckets between replicas; the default keeps them in memory.
+func newRateLimits(db *sql.DB, cfg config.RateLimitConfig) *rateLimits {
+	rl := &rateLimits{limits: cfg.Limits}
+	if cfg.Store == "postgres" {
+		rl.store = ratelimit.NewPostgresStore(db)
+	} else {
+		rl.store = ratelimit.NewMemoryStore()
+	}
+	return rl
+}
+
+// group returns middleware applying the limit of the named group
+func (rl *rateLimits) group(name string) func(http.Handler) http.Handler {
+	return ratelimit.Middleware(rl.store, name, rl.limits[name])
+}
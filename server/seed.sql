@@ -0,0 +1,11 @@
+-- Sample holdings, loaded with `server seed`. Loading again replaces them.
+-- User UIDs (like Firebase)
+-- Replace these with your real Firebase test users if needed
+DELETE FROM holdings WHERE user_id IN ('v69VFq5fjfhjj4IVckGxL4A1UP92');
+
+INSERT INTO holdings (user_id, name, symbol, quantity, currency, cost)
+VALUES
+-- ===== User 1 =====
+('v69VFq5fjfhjj4IVckGxL4A1UP92', 'Vanguard Total Stock Market ETF', 'VTI', 12.34567890, 'USD', 2500.00),
+('v69VFq5fjfhjj4IVckGxL4A1UP92', 'Apple Inc.', 'AAPL', 20.00000000, 'USD', 3000.00),
+('v69VFq5fjfhjj4IVckGxL4A1UP92', 'Tesla Inc.', 'TSLA', 5.00000000, 'USD', 1100.00);
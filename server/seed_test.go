@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// sqlStatements splits script into statements, ignoring comments and
+// semicolons inside string literals. The text after the last semicolon is
+// returned as rest.
+func sqlStatements(script string) (statements []string, rest string) {
+	var current strings.Builder
+	inString := false
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case !inString && c == '-' && strings.HasPrefix(script[i:], "--"):
+			// Skip to the end of the comment
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			current.WriteByte('\n')
+			continue
+		case c == '\'':
+			inString = !inString
+		case !inString && c == ';':
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+		current.WriteByte(c)
+	}
+	return statements, strings.TrimSpace(current.String())
+}
+
+func TestSeedSQL_IsTerminated(t *testing.T) {
+	statements, rest := sqlStatements(seedSQL)
+
+	// Assert nothing follows the last statement
+	if rest != "" {
+		t.Fatalf("Expected seed.sql to end with a semicolon, found %q", rest)
+	}
+	if len(statements) == 0 {
+		t.Fatal("Expected seed.sql to contain statements")
+	}
+
+	for _, stmt := range statements {
+		// Assert no statement is left with a dangling comma or bracket
+		if strings.HasSuffix(stmt, ",") {
+			t.Errorf("Statement ends with a comma: %q", stmt)
+		}
+		if strings.Count(stmt, "(") != strings.Count(stmt, ")") {
+			t.Errorf("Unbalanced parentheses in %q", stmt)
+		}
+	}
+}
+
+func TestSeedSQL_RowsMatchColumns(t *testing.T) {
+	statements, _ := sqlStatements(seedSQL)
+
+	for _, stmt := range statements {
+		if !strings.HasPrefix(stmt, "INSERT") {
+			continue
+		}
+		columns, values, ok := strings.Cut(stmt, "VALUES")
+		if !ok {
+			t.Fatalf("Expected VALUES in %q", stmt)
+		}
+		want := strings.Count(columns, ",") + 1
+
+		// Assert every row has a value for each column
+		for _, tuple := range strings.Split(values, "),") {
+			if got := strings.Count(tuple, ",") + 1; got != want {
+				t.Errorf("Expected %d values, got %d in %q", want, got, strings.TrimSpace(tuple))
+			}
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fif/config"
+	"net/http"
+)
+
+// newHTTPServer creates a server for handler on addr with the configured
+// timeouts
+func newHTTPServer(addr string, handler http.Handler, cfg config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
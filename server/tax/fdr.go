@@ -0,0 +1,103 @@
+package tax
+
+import (
+	"context"
+	"database/sql"
+	"fif/metrics"
+	"fif/portfolio"
+	"time"
+)
+
+// FDRRate is the fair dividend rate: FIF income is 5% of the market value of
+// the interests held at the start of the income year
+const FDRRate = 0.05
+
+// MethodFDR names the fair dividend rate method in reports
+const MethodFDR = "FDR"
+
+// HoldingIncome is the FIF income from one holding. The NZD fields are nil
+// when the holding had no price or FX rate at the start of the year.
+type HoldingIncome struct {
+	Name            string   `json:"name"`
+	Symbol          string   `json:"symbol"`
+	Currency        string   `json:"currency"`
+	Quantity        float64  `json:"quantity"`
+	OpeningValueNZD *float64 `json:"openingValueNzd"`
+	IncomeNZD       *float64 `json:"incomeNzd"`
+}
+
+// Report is a user's FIF income for one income year
+type Report struct {
+	IncomeYear      int     `json:"incomeYear"`
+	Method          string  `json:"method"`
+	OpeningDate     string  `json:"openingDate"`
+	OpeningValueNZD float64 `json:"openingValueNzd"`
+	// CostNZD is the cost of the foreign holdings at the start of the year,
+	// whether or not they had an opening price
+	CostNZD   float64 `json:"costNzd"`
+	IncomeNZD float64 `json:"incomeNzd"`
+	// Exempt is true when that cost was within the de minimis threshold. It
+	// is false when a foreign holding has no NZD cost, since the test cannot
+	// then be made.
+	Exempt bool `json:"exempt"`
+	// Complete is false when a holding is missing an opening price or rate
+	// and so is left out of the totals
+	Complete bool            `json:"complete"`
+	Holdings []HoldingIncome `json:"holdings"`
+}
+
+// FDR computes FIF income under the fair dividend rate method from the
+// valuation at the close of the previous income year. There is no transaction
+// ledger, so no quick sale adjustment is made for holdings bought and sold
+// within the year.
+func FDR(incomeYear int, opening portfolio.Valuation) Report {
+	defer metrics.ObserveCalculation("fdr", time.Now())
+
+	report := Report{
+		IncomeYear:      incomeYear,
+		Method:          MethodFDR,
+		OpeningDate:     opening.Date,
+		OpeningValueNZD: opening.MarketValueNZD,
+		Complete:        opening.Complete,
+		Holdings:        []HoldingIncome{},
+	}
+
+	costKnown := true
+	for _, h := range opening.Holdings {
+		// NZD holdings are not interests in a foreign investment fund
+		if h.Currency != portfolio.BaseCurrency {
+			if h.CostNZD != nil {
+				report.CostNZD += *h.CostNZD
+			} else {
+				costKnown = false
+			}
+		}
+
+		line := HoldingIncome{
+			Name:            h.Name,
+			Symbol:          h.Symbol,
+			Currency:        h.Currency,
+			Quantity:        h.Quantity,
+			OpeningValueNZD: h.MarketValueNZD,
+		}
+		if h.MarketValueNZD != nil {
+			income := *h.MarketValueNZD * FDRRate
+			line.IncomeNZD = &income
+			report.IncomeNZD += income
+		}
+		report.Holdings = append(report.Holdings, line)
+	}
+	report.Exempt = costKnown && report.CostNZD <= DeMinimisThreshold
+	return report
+}
+
+// Calculate values the user's holdings at the close of the previous income
+// year and applies the fair dividend rate. As elsewhere, past dates are valued
+// with current holdings.
+func Calculate(ctx context.Context, db *sql.DB, userID string, incomeYear int) (Report, error) {
+	opening, err := portfolio.Load(ctx, db, userID, YearEnd(incomeYear-1))
+	if err != nil {
+		return Report{}, err
+	}
+	return FDR(incomeYear, opening), nil
+}
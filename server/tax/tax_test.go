@@ -0,0 +1,100 @@
+package tax
+
+import (
+	"fif/portfolio"
+	"testing"
+	"time"
+)
+
+func day(t *testing.T, s string) time.Time {
+	t.Helper()
+	d, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		t.Fatalf("Failed to parse date %q: %v", s, err)
+	}
+	return d
+}
+
+func TestIncomeYear(t *testing.T) {
+	testCases := []struct {
+		date     string
+		expected int
+	}{
+		{date: "2025-03-31", expected: 2025},
+		{date: "2025-04-01", expected: 2026},
+		{date: "2025-12-31", expected: 2026},
+		{date: "2026-01-01", expected: 2026},
+	}
+
+	for _, tc := range testCases {
+		if got := IncomeYear(day(t, tc.date)); got != tc.expected {
+			t.Errorf("IncomeYear(%s): expected %d, got %d", tc.date, tc.expected, got)
+		}
+	}
+}
+
+func TestDate(t *testing.T) {
+	// 31 March 11:30 UTC is already 1 April in New Zealand
+	got := Date(time.Date(2025, time.March, 31, 11, 30, 0, 0, time.UTC))
+
+	// Assert the date moves into the next income year
+	if got.Format("2006-01-02") != "2025-04-01" || IncomeYear(got) != 2026 {
+		t.Errorf("Expected 2025-04-01 in income year 2026, got %s", got.Format("2006-01-02"))
+	}
+}
+
+func TestFDR(t *testing.T) {
+	value, cost, unpricedCost := 40000.0, 30000.0, 30000.0
+	opening := portfolio.Valuation{
+		Date: "2025-03-31",
+		Holdings: []portfolio.HoldingValuation{
+			{Symbol: "VTI", Currency: "USD", Quantity: 100, MarketValueNZD: &value, CostNZD: &cost},
+			{Symbol: "UNPRICED", Currency: "USD", Quantity: 5, MissingPrice: true, CostNZD: &unpricedCost},
+		},
+		MarketValueNZD: value,
+		CostNZD:        cost,
+		Complete:       false,
+	}
+
+	report := FDR(2026, opening)
+
+	// Assert income is 5% of opening value, leaving out the unpriced holding
+	if report.IncomeNZD != 2000 {
+		t.Errorf("Expected income 2000, got %v", report.IncomeNZD)
+	}
+	if report.Holdings[1].IncomeNZD != nil {
+		t.Errorf("Expected no income for a holding without a price, got %v", *report.Holdings[1].IncomeNZD)
+	}
+	// Assert the unpriced holding's cost takes the total over the threshold
+	if report.CostNZD != 60000 {
+		t.Errorf("Expected cost 60000 including the unpriced holding, got %v", report.CostNZD)
+	}
+	if report.Exempt {
+		t.Error("Expected cost over the threshold not to be exempt")
+	}
+	if report.Complete {
+		t.Error("Expected report to be incomplete")
+	}
+}
+
+func TestFDR_ExemptNeedsEveryCost(t *testing.T) {
+	cost, nzdCost := 10000.0, 90000.0
+	opening := portfolio.Valuation{
+		Date: "2025-03-31",
+		Holdings: []portfolio.HoldingValuation{
+			{Symbol: "VTI", Currency: "USD", Quantity: 100, CostNZD: &cost},
+			{Symbol: "FPH", Currency: "NZD", Quantity: 100, CostNZD: &nzdCost},
+		},
+	}
+
+	// Assert NZD holdings are not counted towards the threshold
+	if report := FDR(2026, opening); !report.Exempt || report.CostNZD != 10000 {
+		t.Errorf("Expected exempt with foreign cost 10000, got %v and %v", report.Exempt, report.CostNZD)
+	}
+
+	// Assert a foreign holding without an NZD cost leaves the test unmade
+	opening.Holdings = append(opening.Holdings, portfolio.HoldingValuation{Symbol: "VOD", Currency: "GBP", Quantity: 5, MissingRate: true})
+	if report := FDR(2026, opening); report.Exempt {
+		t.Error("Expected not exempt when a cost is unknown")
+	}
+}
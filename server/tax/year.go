@@ -0,0 +1,50 @@
+// Package tax calculates FIF income for a New Zealand income year
+package tax
+
+import (
+	"time"
+	// The runtime image has no zoneinfo
+	_ "time/tzdata"
+)
+
+// DeMinimisThreshold is the NZ$50,000 FIF cost threshold below which an
+// individual is exempt from the FIF rules
+const DeMinimisThreshold = 50000.0
+
+// nzTime is the time zone tax dates fall in
+var nzTime = mustLoadLocation("Pacific/Auckland")
+
+func mustLoadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+// Date returns the New Zealand date at t as midnight UTC, the form the other
+// dates in this package take. Early in the UTC day it is already the next day
+// in New Zealand.
+func Date(t time.Time) time.Time {
+	y, m, d := t.In(nzTime).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// IncomeYear returns the NZ income year (1 April to 31 March, named by the year
+// it ends in) containing t
+func IncomeYear(t time.Time) int {
+	if t.Month() >= time.April {
+		return t.Year() + 1
+	}
+	return t.Year()
+}
+
+// FilingDeadline returns the IR3 due date for an income year: 7 July after it ends
+func FilingDeadline(incomeYear int) time.Time {
+	return time.Date(incomeYear, time.July, 7, 0, 0, 0, 0, time.UTC)
+}
+
+// YearEnd returns 31 March of an income year
+func YearEnd(incomeYear int) time.Time {
+	return time.Date(incomeYear, time.March, 31, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,78 @@
+// Package users reads and updates the users known to the server, for the
+// admin API and the command line
+package users
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// User is a user as seen by administrators
+type User struct {
+	ID                   string   `json:"id"`
+	Email                string   `json:"email"`
+	Roles                []string `json:"roles"`
+	NotificationsEnabled bool     `json:"notificationsEnabled"`
+	Holdings             int      `json:"holdings"`
+	// CreatedAt is nil for users who only have holdings
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// List returns every known user: those with saved settings and those who only
+// have holdings
+func List(ctx context.Context, db *sql.DB) ([]User, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH ids AS (
+			SELECT id FROM users
+			UNION
+			SELECT DISTINCT user_id FROM holdings
+		)
+		SELECT ids.id,
+			COALESCE(u.email, ''),
+			COALESCE(u.roles, '{}'),
+			COALESCE(u.notifications_enabled, FALSE),
+			(SELECT COUNT(*) FROM holdings h WHERE h.user_id = ids.id),
+			u.created_at
+		FROM ids
+		LEFT JOIN users u ON u.id = ids.id
+		ORDER BY ids.id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		var createdAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Email, pq.Array(&u.Roles), &u.NotificationsEnabled, &u.Holdings, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		if u.Roles == nil {
+			u.Roles = []string{}
+		}
+		if createdAt.Valid {
+			u.CreatedAt = &createdAt.Time
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SetRoles replaces a user's roles, creating the user if needed. Callers
+// validate the roles against middleware.KnownRoles.
+func SetRoles(ctx context.Context, db *sql.DB, userID string, roles []string) error {
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO users (id, roles)
+		VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET roles = EXCLUDED.roles
+	`, userID, pq.Array(roles)); err != nil {
+		return fmt.Errorf("failed to save roles: %w", err)
+	}
+	return nil
+}
@@ -13,10 +13,12 @@ import AccountPage from "./pages/AccountPage";
 import DashboardPage from "./pages/DashboardPage";
 import ProtectedRoute from "./auth/ProtectedRoute";
 import { useAuth } from "./auth/AuthContext";
+import { Features, useFeature } from "./lib/features";
 
 export default function App() {
     const { loggedIn, initializing } = useAuth();
     const [opened, { toggle, close }] = useDisclosure();
+    const taxPage = useFeature(Features.TaxPage);
     if (initializing) {
         return (
             <Center style={{ position: "fixed", inset: 0 }}>
@@ -57,14 +59,16 @@ export default function App() {
                                 </ProtectedRoute>
                             }
                         />
-                        <Route
-                            path="/tax"
-                            element={
-                                <ProtectedRoute>
-                                    <TaxPage />
-                                </ProtectedRoute>
-                            }
-                        />
+                        {taxPage && (
+                            <Route
+                                path="/tax"
+                                element={
+                                    <ProtectedRoute>
+                                        <TaxPage />
+                                    </ProtectedRoute>
+                                }
+                            />
+                        )}
                         <Route
                             path="/account"
                             element={
@@ -1,10 +1,11 @@
 import { authFetch } from "../lib/authFetch";
+import { getConfig } from "../lib/config";
 import { type AccountProfile } from "../models/Account";
 
 export async function getAccountProfile(
     signal?: AbortSignal
 ): Promise<AccountProfile> {
-    const res = await authFetch(`${import.meta.env.VITE_API_URL}/account`, {
+    const res = await authFetch(`${getConfig().apiUrl}/account`, {
         method: "GET",
         signal,
     });
@@ -1,8 +1,9 @@
 import { authFetch } from "../lib/authFetch";
+import { getConfig } from "../lib/config";
 import type { Holding } from "../models/Holding";
 
 export async function getHoldings(signal?: AbortSignal): Promise<Holding[]> {
-    const res = await authFetch(`${import.meta.env.VITE_API_URL}/holdings`, {
+    const res = await authFetch(`${getConfig().apiUrl}/holdings`, {
         method: "GET",
         signal,
     });
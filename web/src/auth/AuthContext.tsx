@@ -6,7 +6,8 @@ import {
     useState,
     type ReactNode,
 } from "react";
-import { supabase } from "../lib/supabase";
+import { getConfig } from "../lib/config";
+import { getSupabase } from "../lib/supabase";
 import type { User } from "@supabase/supabase-js";
 
 interface AuthContextValue {
@@ -26,7 +27,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     useEffect(() => {
         let mounted = true;
 
-        supabase.auth
+        getSupabase().auth
             .getSession()
             .then(({ data, error }) => {
                 if (!mounted) return;
@@ -42,7 +43,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
                 setInitializing(false);
             });
 
-        const { data } = supabase.auth.onAuthStateChange((_event, session) => {
+        const { data } = getSupabase().auth.onAuthStateChange((_event, session) => {
             setUser(session?.user ?? null);
             setInitializing(false);
         });
@@ -59,15 +60,14 @@ export function AuthProvider({ children }: { children: ReactNode }) {
             user,
             initializing,
             signIn: async () => {
-                const redirectTo =
-                    import.meta.env.VITE_SUPABASE_REDIRECT_URL;
+                const redirectTo = getConfig().auth.redirectUrl;
                 if (!redirectTo) {
                     console.error(
-                        "VITE_SUPABASE_REDIRECT_URL is required for sign-in."
+                        "SUPABASE_REDIRECT_URL is required for sign-in."
                     );
                     return;
                 }
-                const { error } = await supabase.auth.signInWithOAuth({
+                const { error } = await getSupabase().auth.signInWithOAuth({
                     provider: "google",
                     options: { redirectTo },
                 });
@@ -76,7 +76,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
                 }
             },
             signOut: async () => {
-                const { error } = await supabase.auth.signOut();
+                const { error } = await getSupabase().auth.signOut();
                 if (error) {
                     console.error("Supabase sign-out failed:", error);
                 }
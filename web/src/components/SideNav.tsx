@@ -1,6 +1,7 @@
 import { AppShell, Button, Stack } from "@mantine/core";
 import NavButton from "./NavButton";
 import { useAuth } from "../auth/AuthContext";
+import { Features, useFeature } from "../lib/features";
 
 export interface SideNavProps {
     close: () => void;
@@ -8,6 +9,7 @@ export interface SideNavProps {
 
 export default function SideNav({ close }: SideNavProps) {
     const { loggedIn, signIn } = useAuth();
+    const taxPage = useFeature(Features.TaxPage);
     return (
         <AppShell.Navbar>
             <Stack gap="md">
@@ -16,9 +18,11 @@ export default function SideNav({ close }: SideNavProps) {
                         <NavButton to="/dashboard" onClick={close}>
                             Dashboard
                         </NavButton>
-                        <NavButton to="/tax" onClick={close}>
-                            Tax
-                        </NavButton>
+                        {taxPage && (
+                            <NavButton to="/tax" onClick={close}>
+                                Tax
+                            </NavButton>
+                        )}
                         <NavButton to="/account" onClick={close}>
                             Account
                         </NavButton>
@@ -1,6 +1,7 @@
 import { AppShell, Burger, Button, Group, Text } from "@mantine/core";
 import NavButton from "./NavButton";
 import { useAuth } from "../auth/AuthContext";
+import { Features, useFeature } from "../lib/features";
 
 export interface TopNavProps {
     opened: boolean;
@@ -9,6 +10,7 @@ export interface TopNavProps {
 
 export default function TopNav({ opened, toggle }: TopNavProps) {
     const { loggedIn, signIn } = useAuth();
+    const taxPage = useFeature(Features.TaxPage);
     return (
         <AppShell.Header>
             <Group h="100%" px="md" justify="space-between">
@@ -17,7 +19,7 @@ export default function TopNav({ opened, toggle }: TopNavProps) {
                 {loggedIn ? (
                     <Group gap="md" visibleFrom="md">
                         <NavButton to="/dashboard">Dashboard</NavButton>
-                        <NavButton to="/tax">Tax</NavButton>
+                        {taxPage && <NavButton to="/tax">Tax</NavButton>}
                     </Group>
                 ) : (
                     <div />
@@ -1,15 +1,45 @@
-import { supabase } from "./supabase";
+import { getSupabase } from "./supabase";
+
+async function withToken(init: RequestInit, token?: string) {
+    const headers = new Headers(init.headers || {});
+    if (token) headers.set("Authorization", `Bearer ${token}`);
+    return { ...init, headers };
+}
+
+async function problemCode(res: Response): Promise<string | undefined> {
+    try {
+        const body = await res.clone().json();
+        return typeof body?.code === "string" ? body.code : undefined;
+    } catch {
+        return undefined;
+    }
+}
 
 export async function authFetch(
     input: RequestInfo | URL,
     init: RequestInit = {}
 ) {
-    const { data, error } = await supabase.auth.getSession();
+    const { data, error } = await getSupabase().auth.getSession();
     if (error) {
         console.error("Failed to fetch Supabase session:", error);
     }
     const token = data.session?.access_token;
-    const headers = new Headers(init.headers || {});
-    if (token) headers.set("Authorization", `Bearer ${token}`);
-    return fetch(input, { ...init, headers });
+    const res = await fetch(input, await withToken(init, token));
+    if (res.status !== 401) return res;
+
+    const code = await problemCode(res);
+    if (code === "token_expired") {
+        // Refresh silently and retry once
+        const { data: refreshed, error: refreshError } =
+            await getSupabase().auth.refreshSession();
+        if (refreshError || !refreshed.session) return res;
+        return fetch(
+            input,
+            await withToken(init, refreshed.session.access_token)
+        );
+    }
+    if (code === "token_revoked") {
+        await getSupabase().auth.signOut();
+    }
+    return res;
 }
@@ -0,0 +1,65 @@
+// Runtime configuration served by the Go server as /config.json, so one build
+// runs in every environment. The VITE_ variables are only a fallback for the
+// Vite dev server, which has no /config.json.
+
+export interface AppConfig {
+    apiUrl: string;
+    environment: string;
+    auth: {
+        provider: string;
+        supabaseUrl?: string;
+        supabaseAnonKey?: string;
+        redirectUrl?: string;
+    };
+    features: Record<string, boolean>;
+    demo: boolean;
+}
+
+let config: AppConfig | undefined;
+
+function fromBuild(): AppConfig {
+    return {
+        apiUrl: import.meta.env.VITE_API_URL ?? "/api",
+        environment: import.meta.env.MODE,
+        auth: {
+            provider: "supabase",
+            supabaseUrl: import.meta.env.VITE_SUPABASE_URL,
+            supabaseAnonKey: import.meta.env.VITE_SUPABASE_ANON_KEY,
+            redirectUrl: import.meta.env.VITE_SUPABASE_REDIRECT_URL,
+        },
+        features: {},
+        demo: false,
+    };
+}
+
+export async function loadConfig(): Promise<AppConfig> {
+    const fallback = fromBuild();
+    try {
+        const res = await fetch("/config.json", { cache: "no-cache" });
+        if (!res.ok) throw new Error(`HTTP ${res.status}`);
+        const runtime = (await res.json()) as Partial<AppConfig>;
+        config = {
+            ...fallback,
+            ...runtime,
+            auth: {
+                ...fallback.auth,
+                ...Object.fromEntries(
+                    Object.entries(runtime.auth ?? {}).filter(([, v]) => v)
+                ),
+            },
+        };
+    } catch (err) {
+        if (import.meta.env.PROD) {
+            console.error("Failed to load /config.json:", err);
+        }
+        config = fallback;
+    }
+    return config;
+}
+
+export function getConfig(): AppConfig {
+    if (!config) {
+        throw new Error("getConfig called before loadConfig");
+    }
+    return config;
+}
@@ -0,0 +1,22 @@
+import { useQuery } from "@tanstack/react-query";
+import { getAccountProfile } from "../api/account";
+import { useAuth } from "../auth/AuthContext";
+import { getConfig } from "./config";
+
+// Feature flags, matching the names in the server's flags package
+export const Features = {
+    TaxPage: "tax-page",
+} as const;
+
+// useFeature reports whether a feature is on: for everyone through
+// /config.json, or for the signed-in user through /api/account
+export function useFeature(name: string): boolean {
+    const { loggedIn } = useAuth();
+    const { data } = useQuery({
+        queryKey: ["account"],
+        queryFn: ({ signal }) => getAccountProfile(signal),
+        enabled: loggedIn,
+        staleTime: 5 * 60 * 1000,
+    });
+    return Boolean(getConfig().features[name] || data?.features?.[name]);
+}
@@ -1,14 +1,20 @@
-import { createClient } from "@supabase/supabase-js";
+import { createClient, type SupabaseClient } from "@supabase/supabase-js";
+import { getConfig } from "./config";
 
-const supabaseUrl = import.meta.env.VITE_SUPABASE_URL;
-const supabaseAnonKey = import.meta.env.VITE_SUPABASE_ANON_KEY;
+let client: SupabaseClient | undefined;
 
-if (!supabaseUrl) {
-    throw new Error("VITE_SUPABASE_URL is required");
-}
+// The client is created on first use, once the runtime config has loaded
+export function getSupabase(): SupabaseClient {
+    if (client) return client;
 
-if (!supabaseAnonKey) {
-    throw new Error("VITE_SUPABASE_ANON_KEY is required");
-}
+    const { supabaseUrl, supabaseAnonKey } = getConfig().auth;
+    if (!supabaseUrl) {
+        throw new Error("SUPABASE_URL is required");
+    }
+    if (!supabaseAnonKey) {
+        throw new Error("SUPABASE_ANON_KEY is required");
+    }
 
-export const supabase = createClient(supabaseUrl, supabaseAnonKey);
+    client = createClient(supabaseUrl, supabaseAnonKey);
+    return client;
+}
@@ -5,22 +5,26 @@ import App from "./App.tsx";
 import { ColorSchemeScript, MantineProvider } from "@mantine/core";
 import { BrowserRouter } from "react-router-dom";
 import { AuthProvider } from "./auth/AuthContext";
+import { loadConfig } from "./lib/config";
 
 import { QueryClient, QueryClientProvider } from "@tanstack/react-query";
 
 const queryClient = new QueryClient();
 
-createRoot(document.getElementById("root")!).render(
-    <StrictMode>
-        <ColorSchemeScript defaultColorScheme="auto" />
-        <MantineProvider defaultColorScheme="auto">
-            <BrowserRouter>
-                <AuthProvider>
-                    <QueryClientProvider client={queryClient}>
-                        <App />
-                    </QueryClientProvider>
-                </AuthProvider>
-            </BrowserRouter>
-        </MantineProvider>
-    </StrictMode>
-);
+// Settings come from the server at runtime, so wait for them before rendering
+loadConfig().then(() => {
+    createRoot(document.getElementById("root")!).render(
+        <StrictMode>
+            <ColorSchemeScript defaultColorScheme="auto" />
+            <MantineProvider defaultColorScheme="auto">
+                <BrowserRouter>
+                    <AuthProvider>
+                        <QueryClientProvider client={queryClient}>
+                            <App />
+                        </QueryClientProvider>
+                    </AuthProvider>
+                </BrowserRouter>
+            </MantineProvider>
+        </StrictMode>
+    );
+});
@@ -1,4 +1,5 @@
 export interface AccountProfile {
     email: string;
     name: string;
+    features: Record<string, boolean>;
 }
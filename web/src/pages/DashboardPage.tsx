@@ -12,6 +12,7 @@ import {
 import { useMediaQuery } from "@mantine/hooks";
 import { useQuery } from "@tanstack/react-query";
 import { authFetch } from "../lib/authFetch";
+import { getConfig } from "../lib/config";
 
 type Holding = {
     name: string;
@@ -22,7 +23,7 @@ type Holding = {
 };
 
 const fetchHoldings = async (): Promise<Holding[]> => {
-    const res = await authFetch(`${import.meta.env.VITE_API_URL}/holdings`, {
+    const res = await authFetch(`${getConfig().apiUrl}/holdings`, {
         method: "GET",
     });
     if (!res.ok) {
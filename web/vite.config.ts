@@ -3,6 +3,10 @@ import react from '@vitejs/plugin-react'
 
 // https://vite.dev/config/
 export default defineConfig({
+  // The server swaps this for a fresh nonce in each index.html it sends
+  html: {
+    cspNonce: '__CSP_NONCE__',
+  },
   plugins: [
     react({
       babel: {